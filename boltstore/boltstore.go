@@ -0,0 +1,377 @@
+// Package boltstore provides a fofm.Store backed by an embedded bbolt
+// key-value file. It lives in its own module so that the core fofm module
+// does not depend on bbolt
+package boltstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/emehrkay/fofm"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// runsBucket holds every run keyed by its sequence
+	runsBucket = []byte("runs")
+
+	// nameBucket and statusBucket hold a nested bucket per name and status.
+	// each nested bucket is keyed by the sequence of the matching runs
+	nameBucket   = []byte("by_name")
+	statusBucket = []byte("by_status")
+
+	// lockBucket holds the migration lock under lockKey
+	lockBucket = []byte("lock")
+	lockKey    = []byte("migration")
+)
+
+const lockRetry = 10 * time.Millisecond
+
+// Open opens, or creates, the bbolt file at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return New(db), nil
+}
+
+// New creates a store using an already opened bbolt database
+func New(db *bolt.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Store keeps every run under an auto-incrementing sequence so that the
+// last run is always the last key. Secondary indexes by name and status make
+// LastRunByName and LastStatusRun a single seek as well
+type Store struct {
+	_         struct{}
+	db        *bolt.DB
+	lockOwner string
+}
+
+// lock is the value stored under lockKey
+type lock struct {
+	Owner    string    `json:"owner"`
+	Acquired time.Time `json:"acquired"`
+}
+
+func (s *Store) DB() *bolt.DB {
+	return s.db
+}
+
+func (s *Store) Connect() error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) CreateStore() error {
+	return s.db.Update(createBuckets)
+}
+
+// Lock holds the migration lock by writing a lock entry. bbolt only allows
+// one process to open the file at a time, so this guards runners sharing a
+// Store within the process
+func (s *Store) Lock(ctx context.Context, staleAfter time.Duration) error {
+	owner := fmt.Sprintf(`%d-%d`, os.Getpid(), time.Now().UnixNano())
+
+	held := lock{}
+	for {
+		var acquired bool
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(lockBucket)
+			if err != nil {
+				return err
+			}
+
+			if value := bucket.Get(lockKey); value != nil {
+				err = json.Unmarshal(value, &held)
+				if err != nil {
+					return err
+				}
+
+				if staleAfter <= 0 || time.Since(held.Acquired) <= staleAfter {
+					return nil
+				}
+			}
+
+			value, err := json.Marshal(lock{Owner: owner, Acquired: time.Now().UTC()})
+			if err != nil {
+				return err
+			}
+
+			acquired = true
+
+			return bucket.Put(lockKey, value)
+		})
+		if err != nil {
+			return err
+		}
+
+		if acquired {
+			s.lockOwner = owner
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf(`the lock is held by %v, acquired %v ago -- %w`, held.Owner, time.Since(held.Acquired).Round(time.Second), ctx.Err())
+		case <-time.After(lockRetry):
+		}
+	}
+}
+
+func (s *Store) Unlock() error {
+	owner := s.lockOwner
+	s.lockOwner = ""
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(lockBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		held := lock{}
+		if value := bucket.Get(lockKey); value != nil {
+			err := json.Unmarshal(value, &held)
+			if err != nil {
+				return err
+			}
+		}
+
+		if held.Owner != owner {
+			return nil
+		}
+
+		return bucket.Delete(lockKey)
+	})
+}
+
+func (s *Store) ClearStore() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, nameBucket, statusBucket} {
+			err := tx.DeleteBucket(name)
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+
+		return createBuckets(tx)
+	})
+}
+
+func (s *Store) LastRun() (*fofm.Migration, error) {
+	var mig *fofm.Migration
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(runsBucket)
+		if runs == nil {
+			return nil
+		}
+
+		_, value := runs.Cursor().Last()
+		if value == nil {
+			return nil
+		}
+
+		var err error
+		mig, err = decode(value)
+
+		return err
+	})
+
+	return found(mig, err)
+}
+
+func (s *Store) LastStatusRun(status string) (*fofm.Migration, error) {
+	return s.lastIndexed(statusBucket, status)
+}
+
+func (s *Store) LastRunByName(name string) (*fofm.Migration, error) {
+	return s.lastIndexed(nameBucket, name)
+}
+
+func (s *Store) List() (fofm.MigrationSet, error) {
+	migs := fofm.MigrationSet{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(runsBucket)
+		if runs == nil {
+			return nil
+		}
+
+		return runs.ForEach(func(key, value []byte) error {
+			mig, err := decode(value)
+			if err != nil {
+				return err
+			}
+
+			migs = append(migs, *mig)
+
+			return nil
+		})
+	})
+
+	return migs, err
+}
+
+func (s *Store) GetAllByName(name string) (fofm.MigrationSet, error) {
+	migs := fofm.MigrationSet{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		index := indexBucket(tx, nameBucket, name)
+		if index == nil {
+			return nil
+		}
+
+		runs := tx.Bucket(runsBucket)
+
+		return index.ForEach(func(key, _ []byte) error {
+			mig, err := decode(runs.Get(key))
+			if err != nil {
+				return err
+			}
+
+			migs = append(migs, *mig)
+
+			return nil
+		})
+	})
+
+	return migs, err
+}
+
+func (s *Store) Save(current fofm.Migration, err error) error {
+	current.Created = time.Now().UTC()
+	current.Error = ""
+	if err != nil {
+		current.Error = err.Error()
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := createBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		runs := tx.Bucket(runsBucket)
+		seq, err := runs.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		current.ID = int(seq)
+		value, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+
+		key := itob(seq)
+		err = runs.Put(key, value)
+		if err != nil {
+			return err
+		}
+
+		err = index(tx, nameBucket, current.Name, key)
+		if err != nil {
+			return err
+		}
+
+		return index(tx, statusBucket, current.Status, key)
+	})
+}
+
+// lastIndexed returns the last run found in the index for value
+func (s *Store) lastIndexed(bucket []byte, value string) (*fofm.Migration, error) {
+	var mig *fofm.Migration
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		index := indexBucket(tx, bucket, value)
+		if index == nil {
+			return nil
+		}
+
+		key, _ := index.Cursor().Last()
+		if key == nil {
+			return nil
+		}
+
+		var err error
+		mig, err = decode(tx.Bucket(runsBucket).Get(key))
+
+		return err
+	})
+
+	return found(mig, err)
+}
+
+func createBuckets(tx *bolt.Tx) error {
+	for _, name := range [][]byte{runsBucket, nameBucket, statusBucket} {
+		_, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func indexBucket(tx *bolt.Tx, bucket []byte, value string) *bolt.Bucket {
+	parent := tx.Bucket(bucket)
+	if parent == nil {
+		return nil
+	}
+
+	return parent.Bucket([]byte(value))
+}
+
+func index(tx *bolt.Tx, bucket []byte, value string, key []byte) error {
+	// bbolt does not allow empty bucket names
+	if value == "" {
+		return nil
+	}
+
+	idx, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(value))
+	if err != nil {
+		return err
+	}
+
+	return idx.Put(key, []byte{})
+}
+
+func found(mig *fofm.Migration, err error) (*fofm.Migration, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if mig == nil {
+		return nil, fofm.NoResultsError{OriginalError: fofm.ErrNoResults}
+	}
+
+	return mig, nil
+}
+
+func decode(value []byte) (*fofm.Migration, error) {
+	mig := fofm.Migration{}
+	err := json.Unmarshal(value, &mig)
+
+	return &mig, err
+}
+
+// itob encodes the sequence big endian so that keys sort in insertion order
+func itob(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+
+	return key
+}
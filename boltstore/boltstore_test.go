@@ -0,0 +1,119 @@
+package boltstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emehrkay/fofm"
+	"github.com/emehrkay/fofm/boltstore"
+	"github.com/emehrkay/fofm/fofmtest/storetest"
+)
+
+func TestStoreSuite(t *testing.T) {
+	storetest.RunStoreSuite(t, func() fofm.Store {
+		store, err := boltstore.Open(filepath.Join(t.TempDir(), "migrations.db"))
+		if err != nil {
+			t.Fatalf(`unable to open store -- %v`, err)
+		}
+
+		return store
+	})
+}
+
+func TestStoreKeepsRunsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.db")
+	store, err := boltstore.Open(path)
+	if err != nil {
+		t.Fatalf(`unable to open store -- %v`, err)
+	}
+
+	err = store.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create store -- %v`, err)
+	}
+
+	for _, name := range []string{"Migration_1_up", "Migration_2_up", "Migration_1_up"} {
+		err = store.Save(fofm.Migration{Name: name, Direction: "up", Status: fofm.STATUS_SUCCESS}, nil)
+		if err != nil {
+			t.Fatalf(`unable to save -- %v`, err)
+		}
+	}
+
+	store.Close()
+
+	store, err = boltstore.Open(path)
+	if err != nil {
+		t.Fatalf(`unable to reopen store -- %v`, err)
+	}
+
+	defer store.Close()
+
+	last, err := store.LastRunByName("Migration_2_up")
+	if err != nil || last.ID != 2 {
+		t.Errorf(`expected Migration_2_up with id 2 got %+v -- %v`, last, err)
+	}
+
+	err = store.Save(fofm.Migration{Name: "Migration_3_up", Direction: "up", Status: fofm.STATUS_SUCCESS}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	last, err = store.LastRun()
+	if err != nil || last.ID != 4 || last.Name != "Migration_3_up" {
+		t.Errorf(`expected Migration_3_up with id 4 got %+v -- %v`, last, err)
+	}
+}
+
+func TestStoreLock(t *testing.T) {
+	store, err := boltstore.Open(filepath.Join(t.TempDir(), "migrations.db"))
+	if err != nil {
+		t.Fatalf(`unable to open store -- %v`, err)
+	}
+
+	defer store.Close()
+
+	err = store.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	other := boltstore.New(store.DB())
+	err = other.Lock(ctx, 0)
+	if err == nil {
+		t.Fatalf(`a held lock should not be acquired`)
+	}
+
+	// the lock is left behind, as if the runner crashed
+	time.Sleep(20 * time.Millisecond)
+
+	err = other.Lock(context.Background(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf(`expected the stale lock to be taken over -- %v`, err)
+	}
+
+	// the original holder no longer owns the lock and can't release it
+	store.Unlock()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = store.Lock(ctx, 0)
+	if err == nil {
+		t.Fatalf(`the lock should still be held by the other runner`)
+	}
+
+	err = other.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+
+	err = store.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock after it was released -- %v`, err)
+	}
+}
@@ -0,0 +1,170 @@
+package fofm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrChecksumsUnavailable is returned by Validate when the source of a
+// migration method, and so its checksum, is unavailable
+var ErrChecksumsUnavailable = errors.New("migration checksums are unavailable")
+
+// MigrationChecksums parses the Go files in dir and returns the checksum of
+// every migration method defined on the receiver type, keyed by the method
+// name. The checksum covers the formatted signature and body, so comment and
+// whitespace changes do not change it. A file that can't be parsed doesn't
+// stop the other files from being checksummed, its error is returned with
+// the checksums that were found
+func MigrationChecksums(dir, receiver string) (map[string]string, error) {
+	checksums := map[string]string{}
+	errs := []error{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return checksums, err
+	}
+
+	fset := token.NewFileSet()
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, 0)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || receiverName(fn) != receiver {
+				continue
+			}
+
+			if _, _, err := MigrationNameParts(fn.Name.Name); err != nil {
+				continue
+			}
+
+			sum, err := funcChecksum(fn)
+			if err != nil {
+				errs = append(errs, fmt.Errorf(`unable to checksum %v -- %w`, fn.Name.Name, err))
+				continue
+			}
+
+			checksums[fn.Name.Name] = sum
+		}
+	}
+
+	return checksums, errors.Join(errs...)
+}
+
+// receiverName returns the type name of a method's receiver or an empty
+// string for functions
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+
+	expr := fn.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+
+	return ""
+}
+
+// funcChecksum hashes the signature and body of fn. They are printed without
+// their original positions so that the layout of the source does not matter
+func funcChecksum(fn *ast.FuncDecl) (string, error) {
+	var buf bytes.Buffer
+
+	for _, node := range []ast.Node{fn.Type, fn.Body} {
+		err := format.Node(&buf, token.NewFileSet(), node)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ChecksumDrift is a migration whose source changed after it was
+// successfully run
+type ChecksumDrift struct {
+	_       struct{}  `json:"-"`
+	Name    string    `json:"name"`
+	Stored  string    `json:"stored"`
+	Current string    `json:"current"`
+	Run     Migration `json:"run"`
+}
+
+// Validate compares the checksum recorded with the last successful run of
+// every migration with the checksum of its current source. Migrations
+// without a stored checksum and those added with Register, which have no
+// source, are skipped. When the source of a migration method is unavailable
+// the drifts of the other migrations are returned with an error wrapping
+// ErrChecksumsUnavailable that names the migrations that weren't validated
+func (m *FOFM) Validate() ([]ChecksumDrift, error) {
+	drifts := []ChecksumDrift{}
+	unavailable := []string{}
+
+	for _, stack := range []MigrationStack{m.UpMigrations, m.DownMigrations} {
+		for _, mig := range stack {
+			current := m.checksums[mig.Name]
+			if current == "" {
+				if m.migrations[mig.Name].source == SOURCE_METHOD {
+					unavailable = append(unavailable, mig.Name)
+				}
+
+				continue
+			}
+
+			all, err := m.DB.GetAllByName(mig.Name)
+			if err != nil {
+				return drifts, err
+			}
+
+			for i := len(all) - 1; i >= 0; i-- {
+				run := all[i]
+				if run.Status != STATUS_SUCCESS || run.Checksum == "" {
+					continue
+				}
+
+				if run.Checksum != current {
+					drifts = append(drifts, ChecksumDrift{
+						Name:    mig.Name,
+						Stored:  run.Checksum,
+						Current: current,
+						Run:     run,
+					})
+				}
+
+				break
+			}
+		}
+	}
+
+	if len(unavailable) > 0 {
+		return drifts, fmt.Errorf(`%w -- %v`, ErrChecksumsUnavailable, strings.Join(unavailable, ", "))
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,238 @@
+package fofm_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emehrkay/fofm"
+	"github.com/emehrkay/fofm/testdata/basemigration"
+)
+
+func writeMigrationSource(t *testing.T, dir, source string) {
+	t.Helper()
+
+	err := os.WriteFile(filepath.Join(dir, "migrations.go"), []byte(source), 0644)
+	if err != nil {
+		t.Fatalf(`unable to write source -- %v`, err)
+	}
+}
+
+func TestMigrationChecksums(t *testing.T) {
+	dir := t.TempDir()
+	writeMigrationSource(t, dir, `package migrations
+
+type Manager struct{}
+type Other struct{}
+
+func (m Manager) Migration_1_up() error {
+	return nil
+}
+
+func (m *Manager) Migration_1_down() error {
+	return nil
+}
+
+func (m Manager) helper() error {
+	return nil
+}
+
+func (o Other) Migration_2_up() error {
+	return nil
+}
+`)
+
+	checksums, err := fofm.MigrationChecksums(dir, "Manager")
+	if err != nil {
+		t.Fatalf(`unable to get checksums -- %v`, err)
+	}
+
+	if len(checksums) != 2 || checksums["Migration_1_up"] == "" || checksums["Migration_1_down"] == "" {
+		t.Fatalf(`expected checksums for the Manager migrations only -- %v`, checksums)
+	}
+
+	// formatting and comment changes do not change the checksum
+	writeMigrationSource(t, dir, `package migrations
+
+type Manager struct{}
+
+// Migration_1_up does nothing
+func (m Manager)   Migration_1_up() error {
+	// nothing to do
+	return   nil
+}
+`)
+
+	reformatted, err := fofm.MigrationChecksums(dir, "Manager")
+	if err != nil {
+		t.Fatalf(`unable to get checksums -- %v`, err)
+	}
+
+	if reformatted["Migration_1_up"] != checksums["Migration_1_up"] {
+		t.Errorf(`formatting should not change the checksum`)
+	}
+
+	writeMigrationSource(t, dir, `package migrations
+
+type Manager struct{}
+
+func (m Manager) Migration_1_up() error {
+	return someOtherFunc()
+}
+`)
+
+	edited, err := fofm.MigrationChecksums(dir, "Manager")
+	if err != nil {
+		t.Fatalf(`unable to get checksums -- %v`, err)
+	}
+
+	if edited["Migration_1_up"] == checksums["Migration_1_up"] {
+		t.Errorf(`editing the body should change the checksum`)
+	}
+}
+
+func TestValidateReportsDrift(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManager{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil || last.Checksum == "" {
+		t.Fatalf(`expected the run to be saved with a checksum -- %+v %v`, last, err)
+	}
+
+	drifts, err := mig.Validate()
+	if err != nil || len(drifts) != 0 {
+		t.Errorf(`expected no drift got %+v -- %v`, drifts, err)
+	}
+
+	// simulate the migration being run before its source was edited
+	edited := *last
+	edited.Checksum = "edited"
+	err = mig.DB.Save(edited, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	drifts, err = mig.Validate()
+	if err != nil {
+		t.Fatalf(`unable to validate -- %v`, err)
+	}
+
+	if len(drifts) != 1 || drifts[0].Name != "Migration_1_up" || drifts[0].Stored != "edited" || drifts[0].Current != last.Checksum {
+		t.Errorf(`expected Migration_1_up to have drifted got %+v`, drifts)
+	}
+}
+
+func TestMigrationChecksumsSkipsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeMigrationSource(t, dir, `package migrations
+
+type Manager struct{}
+
+func (m Manager) Migration_1_up() error {
+	return nil
+}
+`)
+
+	err := os.WriteFile(filepath.Join(dir, "broken.go"), []byte(`package migrations func {`), 0644)
+	if err != nil {
+		t.Fatalf(`unable to write source -- %v`, err)
+	}
+
+	checksums, err := fofm.MigrationChecksums(dir, "Manager")
+	if err == nil || !strings.Contains(err.Error(), "broken.go") {
+		t.Errorf(`expected the broken file to be reported got %v`, err)
+	}
+
+	if checksums["Migration_1_up"] == "" {
+		t.Errorf(`expected the checksums of the other files -- %v`, checksums)
+	}
+}
+
+type TestMigrationManagerNoSource struct {
+	BaseMigrationNoop
+}
+
+func (i TestMigrationManagerNoSource) Migration_1_up() error {
+	return nil
+}
+
+func TestValidateWithoutSource(t *testing.T) {
+	mig, err := fofm.New(getDB(t), TestMigrationManagerNoSource{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Register(2, "registered", func(ctx context.Context) error { return nil }, nil)
+	if err != nil {
+		t.Fatalf(`unable to register -- %v`, err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	_, err = mig.Validate()
+	if !errors.Is(err, fofm.ErrChecksumsUnavailable) || !strings.Contains(err.Error(), "Migration_1_up") || strings.Contains(err.Error(), "registered") {
+		t.Errorf(`expected only Migration_1_up to be reported got %v`, err)
+	}
+}
+
+func TestChecksumsWithBaseMigration(t *testing.T) {
+	mig, err := fofm.New(getDB(t), basemigration.Migrations{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil || last.Checksum == "" {
+		t.Errorf(`expected the run to be saved with a checksum -- %+v %v`, last, err)
+	}
+
+	_, err = mig.Validate()
+	if err != nil {
+		t.Errorf(`expected every migration to be validated -- %v`, err)
+	}
+}
+
+func TestWithChecksums(t *testing.T) {
+	mig, err := fofm.New(getDB(t), TestMigrationManagerNoSource{}, fofm.WithChecksums(map[string]string{
+		"Migration_1_up": "generated",
+	}))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil || last.Checksum != "generated" {
+		t.Errorf(`expected the generated checksum to be recorded -- %+v %v`, last, err)
+	}
+
+	_, err = mig.Validate()
+	if err != nil {
+		t.Errorf(`expected every migration to be validated -- %v`, err)
+	}
+}
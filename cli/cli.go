@@ -0,0 +1,393 @@
+// Package cli provides the status, latest, up, down, rollback, redo, goto and
+// create commands for a fofm manager using only the standard library. It can
+// be used as the whole of a command:
+//
+//	func main() {
+//		manager, _ := fofm.New(store, migrations)
+//		os.Exit(cli.Run(manager, os.Args[1:], os.Stdout))
+//	}
+//
+// or be embedded as a subcommand of an existing cobra or urfave/cli app by
+// passing along the arguments that follow the subcommand. Exec returns an
+// ExitError in place of an exit code for frameworks that expect an error:
+//
+//	&cobra.Command{
+//		Use:                "migrate",
+//		DisableFlagParsing: true,
+//		RunE: func(cmd *cobra.Command, args []string) error {
+//			return cli.New(manager, cmd.OutOrStdout()).Exec(args)
+//		},
+//	}
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/emehrkay/fofm"
+)
+
+const (
+	// EXIT_OK is returned when the command succeeded
+	EXIT_OK = 0
+
+	// EXIT_ERROR is returned when the command failed, for example a
+	// migration returned an error
+	EXIT_ERROR = 1
+
+	// EXIT_USAGE is returned when the command or its arguments are invalid
+	EXIT_USAGE = 2
+)
+
+// Command describes a subcommand so that a host app can register its own
+// subcommand for each of them
+type Command struct {
+	_       struct{}
+	Name    string
+	Args    string
+	Summary string
+}
+
+// Commands are the subcommands that Run understands
+var Commands = []Command{
+	{Name: "status", Summary: "list every migration and its runs"},
+	{Name: "latest", Summary: "run every migration up to the latest"},
+	{Name: "up", Args: "<name>", Summary: "run every migration up to and including name"},
+	{Name: "down", Args: "<name>", Summary: "revert every migration down to and including name"},
+	{Name: "rollback", Args: "[n]", Summary: "revert the last n applied migrations, 1 by default"},
+	{Name: "redo", Summary: "revert and reapply the last applied migration"},
+	{Name: "goto", Args: "<id>", Summary: "apply or revert migrations until id is the last applied, 0 reverts everything"},
+	{Name: "create", Args: "[description]", Summary: "create a new migration file"},
+}
+
+// Run runs the subcommand in args, for example []string{"up", "--json",
+// "Migration_10_up"}, and returns the exit code. Both output and errors are
+// written to out
+func Run(manager *fofm.FOFM, args []string, out io.Writer) int {
+	return New(manager, out).Run(args)
+}
+
+// New creates a CLI that writes to out. Its fields can be changed before it
+// is run
+func New(manager *fofm.FOFM, out io.Writer) *CLI {
+	return &CLI{
+		Manager: manager,
+		Name:    "fofm",
+		Out:     out,
+		Err:     out,
+		Context: context.Background(),
+	}
+}
+
+// CLI holds what the subcommands need
+type CLI struct {
+	_       struct{}
+	Manager *fofm.FOFM
+
+	// Name is the command shown in the usage, for example "myapp migrate"
+	Name string
+
+	// Out receives the output of the subcommands and Err the usage and errors
+	Out io.Writer
+	Err io.Writer
+
+	// Context is passed to the manager's context-aware methods
+	Context context.Context
+}
+
+// ExitError is returned by Exec when the exit code is not EXIT_OK
+type ExitError struct {
+	_    struct{}
+	Code int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf(`exit status %d`, e.Code)
+}
+
+// Exec is Run for frameworks that expect an error. The error, which has
+// already been written to Err, is an ExitError
+func (c *CLI) Exec(args []string) error {
+	code := c.Run(args)
+	if code == EXIT_OK {
+		return nil
+	}
+
+	return ExitError{Code: code}
+}
+
+// Run runs the subcommand in args and returns the exit code
+func (c *CLI) Run(args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return EXIT_USAGE
+	}
+
+	name, args := args[0], args[1:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		c.usage()
+		return EXIT_OK
+	}
+
+	var command *Command
+	for i := range Commands {
+		if Commands[i].Name == name {
+			command = &Commands[i]
+		}
+	}
+
+	if command == nil {
+		fmt.Fprintf(c.Err, "unknown command %q\n\n", name)
+		c.usage()
+		return EXIT_USAGE
+	}
+
+	flags := flag.NewFlagSet(c.Name+" "+name, flag.ContinueOnError)
+	flags.SetOutput(c.Err)
+	asJSON := flags.Bool("json", false, "write the output as json")
+	flags.Usage = func() {
+		fmt.Fprintf(c.Err, "usage: %s %s [--json] %s\n\n%s\n", c.Name, command.Name, command.Args, command.Summary)
+		flags.PrintDefaults()
+	}
+
+	args, err := parse(flags, args)
+	if err == flag.ErrHelp {
+		return EXIT_OK
+	}
+
+	if err != nil {
+		return EXIT_USAGE
+	}
+
+	if command.Args == "" && len(args) > 0 {
+		fmt.Fprintf(c.Err, "%s does not accept arguments\n\n", name)
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	if strings.HasPrefix(command.Args, "<") && len(args) != 1 {
+		fmt.Fprintf(c.Err, "%s requires a migration %s\n\n", name, strings.Trim(command.Args, "<>"))
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	rollback := 1
+	if name == "rollback" && len(args) > 0 {
+		rollback, err = strconv.Atoi(args[0])
+		if err != nil || len(args) > 1 {
+			fmt.Fprintf(c.Err, "rollback accepts a single number\n\n")
+			flags.Usage()
+			return EXIT_USAGE
+		}
+	}
+
+	var target int64
+	if name == "goto" {
+		target, err = strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Fprintf(c.Err, "goto accepts a migration id\n\n")
+			flags.Usage()
+			return EXIT_USAGE
+		}
+	}
+
+	var result any
+	switch name {
+	case "status":
+		result, err = c.status(*asJSON)
+	case "latest":
+		result, err = c.migrate(*asJSON, func(ctx context.Context) error {
+			return c.Manager.LatestContext(ctx)
+		})
+	case "up":
+		result, err = c.migrate(*asJSON, func(ctx context.Context) error {
+			return c.Manager.UpContext(ctx, args[0])
+		})
+	case "down":
+		result, err = c.migrate(*asJSON, func(ctx context.Context) error {
+			return c.Manager.DownContext(ctx, args[0])
+		})
+	case "rollback":
+		result, err = c.migrate(*asJSON, func(ctx context.Context) error {
+			return c.Manager.RollbackContext(ctx, rollback)
+		})
+	case "redo":
+		result, err = c.migrate(*asJSON, func(ctx context.Context) error {
+			return c.Manager.RedoContext(ctx)
+		})
+	case "goto":
+		result, err = c.migrate(*asJSON, func(ctx context.Context) error {
+			return c.Manager.GotoContext(ctx, target)
+		})
+	case "create":
+		result, err = c.create(*asJSON, strings.Join(args, " "))
+	}
+
+	if *asJSON {
+		if encodeErr := c.writeJSON(result, err); encodeErr != nil {
+			fmt.Fprintf(c.Err, "unable to write json -- %v\n", encodeErr)
+			return EXIT_ERROR
+		}
+	} else if err != nil {
+		fmt.Fprintf(c.Err, "%s failed -- %v\n", name, err)
+	}
+
+	if err != nil {
+		return EXIT_ERROR
+	}
+
+	return EXIT_OK
+}
+
+func (c *CLI) usage() {
+	fmt.Fprintf(c.Err, "usage: %s <command> [--json] [arguments]\n\ncommands:\n", c.Name)
+
+	writer := tabwriter.NewWriter(c.Err, 0, 8, 2, ' ', 0)
+	for _, command := range Commands {
+		fmt.Fprintf(writer, "  %s %s\t%s\n", command.Name, command.Args, command.Summary)
+	}
+
+	writer.Flush()
+}
+
+func (c *CLI) status(asJSON bool) (any, error) {
+	status, err := c.Manager.Status()
+	if err != nil || asJSON {
+		return status, err
+	}
+
+	headers := []string{"ORDER", "MIGRATION", "DESCRIPTION", "STATE", "RUNS"}
+	writer := tabwriter.NewWriter(c.Out, 0, 8, 1, '\t', tabwriter.AlignRight)
+	fmt.Fprintln(writer, strings.Join(headers, "\t"))
+
+	for i, mig := range status.Migrations {
+		runs := []string{}
+		for _, run := range mig.Runs {
+			runs = append(runs, fmt.Sprintf(`(%s %s)`, run.Status, run.Timestamp))
+		}
+
+		if len(mig.Runs) == 0 {
+			runs = append(runs, "-")
+		}
+
+		description := mig.Migration.Description
+		if description == "" {
+			description = "-"
+		}
+
+		row := []string{
+			fmt.Sprintf("%v", i),
+			mig.Migration.Name,
+			description,
+			mig.State,
+			strings.Join(runs, " "),
+		}
+
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	return status, writer.Flush()
+}
+
+// runsKey is the context key of the runs that a migrate call collects
+type runsKey struct{}
+
+// hooked are the managers that the runs collecting hook was added to. It is
+// only added once no matter how many CLIs share a manager
+var hooked sync.Map
+
+// migrate runs fn and reports the runs that it recorded. The runs are
+// collected by an AfterAll hook from the batches started with fn's context,
+// so runs recorded by other runners aren't reported
+func (c *CLI) migrate(asJSON bool, fn func(ctx context.Context) error) (any, error) {
+	if _, loaded := hooked.LoadOrStore(c.Manager, true); !loaded {
+		fofm.WithAfterAll(func(ctx context.Context, summary fofm.RunSummary) {
+			if runs, ok := ctx.Value(runsKey{}).(*fofm.MigrationSet); ok {
+				*runs = append(*runs, summary.Migrations...)
+			}
+		})(c.Manager)
+	}
+
+	runs := fofm.MigrationSet{}
+	err := fn(context.WithValue(c.Context, runsKey{}, &runs))
+
+	if !asJSON {
+		if len(runs) == 0 && err == nil {
+			fmt.Fprintln(c.Out, "nothing to run")
+		}
+
+		for _, run := range runs {
+			fmt.Fprintf(c.Out, "%s\t%s\n", run.Name, run.Status)
+		}
+	}
+
+	return runs, err
+}
+
+func (c *CLI) create(asJSON bool, description string) (any, error) {
+	path, err := c.Manager.CreateMigration(description)
+	if err != nil {
+		return nil, err
+	}
+
+	if !asJSON {
+		fmt.Fprintln(c.Out, path)
+	}
+
+	return map[string]string{"path": path}, nil
+}
+
+// writeJSON writes the result of a subcommand along with its error
+func (c *CLI) writeJSON(result any, err error) error {
+	output := struct {
+		Result any    `json:"result,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}{
+		Result: result,
+	}
+
+	if err != nil {
+		output.Error = err.Error()
+	}
+
+	encoder := json.NewEncoder(c.Out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(output)
+}
+
+// parse parses the flags wherever they are in args, so both
+// "up --json Migration_1_up" and "up Migration_1_up --json" work, and returns
+// the remaining arguments. Everything after "--" is an argument
+func parse(flags *flag.FlagSet, args []string) ([]string, error) {
+	remaining := []string{}
+
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse stops after consuming the "--" that ends the flags
+		consumed := len(args) - flags.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(remaining, flags.Args()...), nil
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return remaining, nil
+		}
+
+		remaining = append(remaining, args[0])
+		args = args[1:]
+	}
+}
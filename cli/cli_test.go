@@ -0,0 +1,311 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/emehrkay/fofm"
+	"github.com/emehrkay/fofm/cli"
+)
+
+type testMigrations struct{}
+
+func (t testMigrations) GetPackageName() string {
+	return "migrations"
+}
+
+func (t testMigrations) GetMigrationsPath() string {
+	return ""
+}
+
+var MigrationUpFunc2 = func() error {
+	return nil
+}
+
+func (t testMigrations) Migration_1_create_users_up() error {
+	return nil
+}
+
+func (t testMigrations) Migration_1_create_users_down() error {
+	return nil
+}
+
+func (t testMigrations) Migration_2_up() error {
+	return MigrationUpFunc2()
+}
+
+func (t testMigrations) Migration_2_down() error {
+	return nil
+}
+
+func getManager(t *testing.T) (*fofm.FOFM, map[string][]byte) {
+	written := map[string][]byte{}
+	writer := func(ins *fofm.FOFM) error {
+		ins.Writer = func(filename string, data []byte, perm fs.FileMode) error {
+			written[filename] = data
+			return nil
+		}
+
+		return nil
+	}
+
+	manager, err := fofm.New(fofm.NewMemoryStore(), testMigrations{}, writer)
+	if err != nil {
+		t.Fatalf(`unable to create manager -- %v`, err)
+	}
+
+	return manager, written
+}
+
+func TestRunUsage(t *testing.T) {
+	manager, _ := getManager(t)
+
+	for args, expected := range map[string]int{
+		"":               cli.EXIT_USAGE,
+		"help":           cli.EXIT_OK,
+		"unknown":        cli.EXIT_USAGE,
+		"up":             cli.EXIT_USAGE,
+		"down a b":       cli.EXIT_USAGE,
+		"status --nope":  cli.EXIT_USAGE,
+		"latest --help":  cli.EXIT_OK,
+		"status --json=": cli.EXIT_USAGE,
+		"status extra":   cli.EXIT_USAGE,
+		"rollback two":   cli.EXIT_USAGE,
+		"goto":           cli.EXIT_USAGE,
+		"goto latest":    cli.EXIT_USAGE,
+	} {
+		out := &bytes.Buffer{}
+		code := cli.Run(manager, strings.Fields(args), out)
+		if code != expected {
+			t.Errorf(`expected %q to exit with %v got %v -- %v`, args, expected, code, out.String())
+		}
+
+		if out.Len() == 0 {
+			t.Errorf(`expected %q to write the usage or an error`, args)
+		}
+	}
+}
+
+func TestRunLatestAndStatus(t *testing.T) {
+	manager, _ := getManager(t)
+	out := &bytes.Buffer{}
+
+	code := cli.Run(manager, []string{"latest"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected latest to succeed got %v -- %v`, code, out.String())
+	}
+
+	for _, expected := range []string{"Migration_1_create_users_up\tsuccess", "Migration_2_up\tsuccess"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf(`expected the output to contain %q -- %v`, expected, out.String())
+		}
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"latest"}, out)
+	if code != cli.EXIT_OK || !strings.Contains(out.String(), "nothing to run") {
+		t.Errorf(`expected nothing to run got %v -- %v`, code, out.String())
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"status"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected status to succeed got %v -- %v`, code, out.String())
+	}
+
+	for _, expected := range []string{"STATE", "Migration_1_create_users_up", "create users", fofm.STATE_APPLIED, "(success"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf(`expected the status to contain %q -- %v`, expected, out.String())
+		}
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	manager, _ := getManager(t)
+	out := &bytes.Buffer{}
+
+	code := cli.Run(manager, []string{"up", "Migration_1_create_users_up", "--json"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected up to succeed got %v -- %v`, code, out.String())
+	}
+
+	runs := struct {
+		Result fofm.MigrationSet `json:"result"`
+		Error  string            `json:"error"`
+	}{}
+	err := json.Unmarshal(out.Bytes(), &runs)
+	if err != nil {
+		t.Fatalf(`unable to decode the output -- %v -- %v`, err, out.String())
+	}
+
+	if len(runs.Result) != 1 || runs.Result[0].Name != "Migration_1_create_users_up" || runs.Error != "" {
+		t.Errorf(`unexpected runs -- %+v`, runs)
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"status", "--json"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected status to succeed got %v -- %v`, code, out.String())
+	}
+
+	status := struct {
+		Result fofm.MigrationSetStatus `json:"result"`
+	}{}
+	err = json.Unmarshal(out.Bytes(), &status)
+	if err != nil {
+		t.Fatalf(`unable to decode the output -- %v -- %v`, err, out.String())
+	}
+
+	if len(status.Result.Migrations) != 2 || len(status.Result.Migrations[0].Runs) != 1 {
+		t.Errorf(`unexpected status -- %+v`, status)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	manager, _ := getManager(t)
+	out := &bytes.Buffer{}
+
+	MigrationUpFunc2Orig := MigrationUpFunc2
+	MigrationUpFunc2 = func() error {
+		return errors.New("some failure")
+	}
+
+	code := cli.Run(manager, []string{"latest", "--json"}, out)
+	MigrationUpFunc2 = MigrationUpFunc2Orig
+
+	if code != cli.EXIT_ERROR {
+		t.Errorf(`expected latest to fail got %v -- %v`, code, out.String())
+	}
+
+	if !strings.Contains(out.String(), "some failure") || !strings.Contains(out.String(), fofm.STATUS_FAILURE) {
+		t.Errorf(`expected the failure to be reported -- %v`, out.String())
+	}
+
+	c := cli.New(manager, &bytes.Buffer{})
+	err := c.Exec([]string{"down"})
+	exitErr, ok := err.(cli.ExitError)
+	if !ok || exitErr.Code != cli.EXIT_USAGE {
+		t.Errorf(`expected an ExitError with %v got %v`, cli.EXIT_USAGE, err)
+	}
+
+	err = c.Exec([]string{"down", "Migration_2_down"})
+	if err != nil {
+		t.Errorf(`expected down to succeed got %v`, err)
+	}
+}
+
+func TestRunCreate(t *testing.T) {
+	manager, written := getManager(t)
+	out := &bytes.Buffer{}
+
+	code := cli.Run(manager, []string{"create", "add", "user", "email"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected create to succeed got %v -- %v`, code, out.String())
+	}
+
+	path := strings.TrimSpace(out.String())
+	if !strings.HasSuffix(path, "_add_user_email.go") {
+		t.Errorf(`expected the path of the new migration got %v`, path)
+	}
+
+	if _, ok := written[path]; !ok {
+		t.Errorf(`expected %v to be written got %v`, path, written)
+	}
+}
+
+func TestRunRollbackAndRedo(t *testing.T) {
+	manager, _ := getManager(t)
+	out := &bytes.Buffer{}
+
+	code := cli.Run(manager, []string{"latest"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected latest to succeed got %v -- %v`, code, out.String())
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"redo"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected redo to succeed got %v -- %v`, code, out.String())
+	}
+
+	if out.String() != "Migration_2_down\tsuccess\nMigration_2_up\tsuccess\n" {
+		t.Errorf(`unexpected redo output -- %v`, out.String())
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"rollback", "2"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected rollback to succeed got %v -- %v`, code, out.String())
+	}
+
+	if out.String() != "Migration_2_down\tsuccess\nMigration_1_create_users_down\tsuccess\n" {
+		t.Errorf(`unexpected rollback output -- %v`, out.String())
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"redo"}, out)
+	if code != cli.EXIT_ERROR {
+		t.Errorf(`expected redo to fail without applied migrations got %v -- %v`, code, out.String())
+	}
+}
+
+func TestRunGoto(t *testing.T) {
+	manager, _ := getManager(t)
+	out := &bytes.Buffer{}
+
+	code := cli.Run(manager, []string{"goto", "2"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected goto to succeed got %v -- %v`, code, out.String())
+	}
+
+	out.Reset()
+	code = cli.Run(manager, []string{"goto", "0"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected goto to succeed got %v -- %v`, code, out.String())
+	}
+
+	if out.String() != "Migration_2_down\tsuccess\nMigration_1_create_users_down\tsuccess\n" {
+		t.Errorf(`unexpected goto output -- %v`, out.String())
+	}
+}
+
+func TestRunStopsParsingFlagsAfterDoubleDash(t *testing.T) {
+	manager, written := getManager(t)
+	out := &bytes.Buffer{}
+
+	code := cli.Run(manager, []string{"create", "--", "--json", "flag"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected create to succeed got %v -- %v`, code, out.String())
+	}
+
+	path := strings.TrimSpace(out.String())
+	if _, ok := written[path]; !ok || !strings.HasSuffix(path, "_json_flag.go") {
+		t.Errorf(`expected --json to be part of the description got %v`, out.String())
+	}
+}
+
+func TestRunOnlyReportsItsOwnRuns(t *testing.T) {
+	manager, _ := getManager(t)
+	err := fofm.WithBeforeAll(func(ctx context.Context) error {
+		// another runner records a run while this one is running
+		return manager.DB.Save(fofm.Migration{Name: "Migration_3_up", Status: fofm.STATUS_SUCCESS}, nil)
+	})(manager)
+	if err != nil {
+		t.Fatalf(`unable to add hook -- %v`, err)
+	}
+
+	out := &bytes.Buffer{}
+	code := cli.Run(manager, []string{"latest"}, out)
+	if code != cli.EXIT_OK {
+		t.Fatalf(`expected latest to succeed got %v -- %v`, code, out.String())
+	}
+
+	if strings.Contains(out.String(), "Migration_3_up") || !strings.Contains(out.String(), "Migration_2_up") {
+		t.Errorf(`expected only the runs of this command got %v`, out.String())
+	}
+}
@@ -1,7 +1,9 @@
 package fofm
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -44,10 +46,45 @@ type Store interface {
 	// have been saved to the store
 	List() (MigrationSet, error)
 
-	// Save should insert a new record
+	// Save should insert a new record. Every field of current, including the
+	// Duration, Host, PID, Version and Operator that describe the run, should
+	// be kept so that they are returned by the other methods. ID and Created
+	// are set by the store
 	Save(current Migration, err error) error
 }
 
+// TxStore is an optional Store capability. When the store's records live in
+// the same database that was passed to WithSQLDB, successful transactional
+// migrations are saved inside of the migration's transaction so that a
+// migration can never be applied without being recorded
+type TxStore interface {
+	Store
+
+	// SQLDB should return the database where the records are kept
+	SQLDB() *sql.DB
+
+	// SaveTx is Save using the provided transaction
+	SaveTx(tx *sql.Tx, current Migration, err error) error
+}
+
+// Locker is an optional Store capability. When a store is a Locker, Latest,
+// Up and Down hold its lock while they work out what to run and run it so
+// that multiple runners sharing a store never run the same migration
+type Locker interface {
+	// Lock should block until the lock is held or the context is done. A lock
+	// held for longer than staleAfter should be considered abandoned and be
+	// taken over. A staleAfter of 0 means that locks are never stale
+	Lock(ctx context.Context, staleAfter time.Duration) error
+
+	// Unlock should release a lock acquired by Lock
+	Unlock() error
+}
+
+// ErrLockNotAcquired is returned when the migration lock could not be
+// acquired before the lock timeout. The stores packaged with fofm wrap it with
+// the lock's holder
+var ErrLockNotAcquired = errors.New("unable to acquire the migration lock")
+
 // NoResultsError should be used in place of a
 // store's no results error
 type NoResultsError struct {
@@ -61,7 +98,7 @@ func (re NoResultsError) Error() string {
 
 const (
 	functionalMigrationTableName = "function_migrations"
-	selectFields                 = "id, name, direction, status, error, timestamp, created"
+	selectFields                 = "id, name, direction, status, error, timestamp, created, checksum, duration, host, pid, version, operator"
 )
 
 func NewSQLiteWithTableName(filepath, tablename string) (*SQLite, error) {
@@ -71,9 +108,8 @@ func NewSQLiteWithTableName(filepath, tablename string) (*SQLite, error) {
 	}
 
 	db := &SQLite{
-		filepath:  filepath,
-		db:        ins,
-		tablename: tablename,
+		SQLStore: NewSQLStore(ins, SQLiteDialect{}, tablename),
+		filepath: filepath,
 	}
 
 	return db, nil
@@ -83,295 +119,48 @@ func NewSQLite(filepath string) (*SQLite, error) {
 	return NewSQLiteWithTableName(filepath, functionalMigrationTableName)
 }
 
+// SQLite is a SQLStore using the SQLiteDialect on a database that it opens
+// itself
 type SQLite struct {
-	_         struct{}
-	filepath  string
-	tablename string
-	db        *sql.DB
+	*SQLStore
+	filepath string
 }
 
-func (s *SQLite) Connect() error {
-	return nil
+// SQLiteDialect stores timestamps as RFC1123Z text
+type SQLiteDialect struct{}
+
+func (d SQLiteDialect) Placeholder(n int) string {
+	return dollarPlaceholder(n)
 }
 
-func (s *SQLite) Close() error {
-	return s.db.Close()
+func (d SQLiteDialect) QuoteIdentifier(name string) string {
+	return doubleQuote(name)
 }
 
-func (s *SQLite) CreateStore() error {
-	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+func (d SQLiteDialect) CreateStatements(schema, table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 		id INTEGER PRIMARY KEY,
 		name TEXT NOT NULL, 
 		direction TEXT NOT NULL, 
 		timestamp TEXT NOT NULL, 
 		status TEXT NOT NULL,
 		error TEXT NULL,
-		created TEXT NOT NULL
-	)`, s.tablename)
-	_, err := s.db.Exec(query)
-
-	return err
-}
-
-func (s *SQLite) ClearStore() error {
-	query := fmt.Sprintf(`
-	DELETE FROM %s`, s.tablename)
-	_, err := s.db.Exec(query)
-
-	return err
-}
-
-func (s *SQLite) LastRun() (*Migration, error) {
-	query := fmt.Sprintf(`
-	SELECT
-		%s
-	FROM
-		%s
-	ORDER BY
-		id DESC
-	LIMIT 1`, selectFields, s.tablename)
-	mig := Migration{}
-	var timestamp, created string
-	fields := []any{
-		&mig.ID,
-		&mig.Name,
-		&mig.Direction,
-		&mig.Status,
-		&mig.Error,
-		&timestamp,
-		&created,
-	}
-	err := s.db.QueryRow(query).Scan(fields...)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, NoResultsError{OriginalError: err}
-		}
-
-		return nil, err
-	}
-
-	err = mig.CreatedFromString(created)
-	if err != nil {
-		return nil, err
-	}
-
-	err = mig.TimestampFromString(created)
-	if err != nil {
-		return nil, err
-	}
-
-	return &mig, nil
-}
-
-func (s *SQLite) LastStatusRun(status string) (*Migration, error) {
-	query := fmt.Sprintf(`
-	SELECT
-		%s
-	FROM
-		%s
-	WHERE
-		status = $1
-	ORDER BY
-		id DESC
-	LIMIT 1`, selectFields, s.tablename)
-	mig := Migration{}
-	var timestamp, created string
-	fields := []any{
-		&mig.ID,
-		&mig.Name,
-		&mig.Direction,
-		&mig.Status,
-		&mig.Error,
-		&timestamp,
-		&created,
-	}
-
-	err := s.db.QueryRow(query, status).Scan(fields...)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, NoResultsError{OriginalError: err}
-		}
-
-		return nil, err
-	}
-
-	err = mig.CreatedFromString(created)
-	if err != nil {
-		return nil, err
+		created TEXT NOT NULL,
+		checksum TEXT NULL,
+		duration BIGINT NULL,
+		host TEXT NULL,
+		pid BIGINT NULL,
+		version TEXT NULL,
+		operator TEXT NULL
+	)`, table),
 	}
-
-	err = mig.TimestampFromString(created)
-	if err != nil {
-		return nil, err
-	}
-
-	return &mig, err
-}
-
-func (s *SQLite) LastRunByName(name string) (*Migration, error) {
-	query := fmt.Sprintf(`
-	SELECT
-		%s
-	FROM
-		%s
-	WHERE
-		name = $1
-	ORDER BY
-		id DESC
-	LIMIT 1`, selectFields, s.tablename)
-	mig := Migration{}
-	var timestamp, created string
-	fields := []any{
-		&mig.ID,
-		&mig.Name,
-		&mig.Direction,
-		&mig.Status,
-		&mig.Error,
-		&timestamp,
-		&created,
-	}
-	err := s.db.QueryRow(query, name).Scan(fields...)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, NoResultsError{OriginalError: err}
-		}
-
-		return nil, err
-	}
-
-	err = mig.CreatedFromString(created)
-	if err != nil {
-		return nil, err
-	}
-
-	err = mig.TimestampFromString(created)
-	if err != nil {
-		return nil, err
-	}
-
-	return &mig, err
-}
-
-func (s *SQLite) List() (MigrationSet, error) {
-	migs := MigrationSet{}
-	query := fmt.Sprintf(`
-	SELECT
-		%s
-	FROM
-		%s
-	ORDER BY
-		id ASC
-	`, selectFields, s.tablename)
-	rows, err := s.db.Query(query)
-
-	if err != nil {
-		return migs, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		mig := Migration{}
-		var timestamp, created string
-		fields := []any{
-			&mig.ID,
-			&mig.Name,
-			&mig.Direction,
-			&mig.Status,
-			&mig.Error,
-			&timestamp,
-			&created,
-		}
-		err = rows.Scan(fields...)
-
-		if err != nil {
-			return migs, err
-		}
-
-		err = mig.CreatedFromString(created)
-		if err != nil {
-			return migs, err
-		}
-
-		err = mig.TimestampFromString(created)
-		if err != nil {
-			return migs, err
-		}
-
-		migs = append(migs, mig)
-	}
-
-	return migs, err
 }
 
-func (s *SQLite) GetAllByName(name string) (MigrationSet, error) {
-	migs := MigrationSet{}
-	query := fmt.Sprintf(`
-	SELECT
-		%s
-	FROM
-		%s
-	WHERE
-		name = $1
-	ORDER BY
-		id ASC
-	`, selectFields, s.tablename)
-	rows, err := s.db.Query(query, name)
-
-	if err != nil {
-		return migs, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		mig := Migration{}
-		var timestamp, created string
-		fields := []any{
-			&mig.ID,
-			&mig.Name,
-			&mig.Direction,
-			&mig.Status,
-			&mig.Error,
-			&timestamp,
-			&created,
-		}
-		err = rows.Scan(fields...)
-
-		if err != nil {
-			return migs, err
-		}
-
-		err = mig.CreatedFromString(created)
-		if err != nil {
-			return migs, err
-		}
-
-		err = mig.TimestampFromString(created)
-		if err != nil {
-			return migs, err
-		}
-
-		migs = append(migs, mig)
-	}
-
-	return migs, err
+func (d SQLiteDialect) EncodeTime(t time.Time) any {
+	return t.UTC().Format(time.RFC1123Z)
 }
 
-func (s *SQLite) Save(current Migration, err error) error {
-	query := fmt.Sprintf(`
-	INSERT INTO
-		%s (name, direction, status, error, timestamp, created)
-	VALUES
-		($1, $2, $3, $4, $5, $6)`, s.tablename)
-
-	var errText string
-	if err != nil {
-		errText = err.Error()
-	}
-
-	now := time.Now().UTC().Format(time.RFC1123Z)
-	_, err = s.db.Exec(query, current.Name, current.Direction, current.Status, errText, current.Timestamp, now)
-
-	return err
+func (d SQLiteDialect) DecodeTime(src any) (time.Time, error) {
+	return decodeTime(src)
 }
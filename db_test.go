@@ -0,0 +1,361 @@
+package fofm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emehrkay/fofm"
+	"github.com/emehrkay/fofm/fofmtest/storetest"
+)
+
+func TestSQLiteStoreSuite(t *testing.T) {
+	storetest.RunStoreSuite(t, func() fofm.Store {
+		return getDB(t)
+	})
+}
+
+func TestMemoryStoreSuite(t *testing.T) {
+	storetest.RunStoreSuite(t, func() fofm.Store {
+		return fofm.NewMemoryStore()
+	}, storetest.LocksNeverStale)
+}
+
+func TestMemoryStoreSnapshotAndRestore(t *testing.T) {
+	store := fofm.NewMemoryStore()
+	mig, err := fofm.New(store, TestMigrationManagerMultiple{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Up("Migration_5_up")
+	if err != nil {
+		t.Fatalf("unable to run migration -- %s", err)
+	}
+
+	snapshot := store.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Name != "Migration_1_up" || snapshot[1].Name != "Migration_5_up" {
+		t.Fatalf(`unexpected snapshot -- %+v`, snapshot)
+	}
+
+	// modifying the snapshot should not modify the store
+	snapshot[0].Name = "changed"
+	list, _ := store.List()
+	if list[0].Name != "Migration_1_up" {
+		t.Errorf(`the snapshot should be a copy`)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %s", err)
+	}
+
+	store.Restore(snapshot[1:])
+	list, _ = store.List()
+	if len(list) != 1 || list[0].Name != "Migration_5_up" {
+		t.Errorf(`unexpected records after restore -- %+v`, list)
+	}
+
+	err = store.Save(fofm.Migration{Name: "Migration_10_up"}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	last, _ := store.LastRun()
+	if last.ID <= snapshot[1].ID {
+		t.Errorf(`expected an id after the restored records got %v`, last.ID)
+	}
+}
+
+func TestMemoryStoreIsSafeForConcurrentUse(t *testing.T) {
+	store := fofm.NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Save(fofm.Migration{Name: "Migration_1_up", Status: fofm.STATUS_SUCCESS}, nil)
+			store.LastRun()
+			store.GetAllByName("Migration_1_up")
+		}()
+	}
+
+	wg.Wait()
+
+	list, _ := store.List()
+	if len(list) != 50 {
+		t.Errorf(`expected 50 records got %v`, len(list))
+	}
+}
+
+func TestFileStoreSuite(t *testing.T) {
+	storetest.RunStoreSuite(t, func() fofm.Store {
+		return fofm.NewFileStore(filepath.Join(t.TempDir(), "migrations.json"))
+	})
+}
+
+func TestFileStoreIsHumanReadableJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.json")
+	mig, err := fofm.New(fofm.NewFileStore(path), TestMigrationManager{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %s", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf(`unable to read the file -- %v`, err)
+	}
+
+	data := struct {
+		Migrations []map[string]any `json:"migrations"`
+	}{}
+	err = json.Unmarshal(content, &data)
+	if err != nil {
+		t.Fatalf(`the file is not valid json -- %v`, err)
+	}
+
+	if len(data.Migrations) != 1 || data.Migrations[0]["name"] != "Migration_1_up" {
+		t.Errorf(`unexpected file content -- %s`, content)
+	}
+
+	if !strings.Contains(string(content), "\n  ") {
+		t.Errorf(`expected the file to be indented -- %s`, content)
+	}
+}
+
+func TestFileStoreConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.json")
+
+	// every writer gets its own store, the same as separate processes would
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := fofm.NewFileStore(path)
+			errs <- store.Save(fofm.Migration{Name: "Migration_1_up", Status: fofm.STATUS_SUCCESS}, nil)
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf(`unable to save -- %v`, err)
+		}
+	}
+
+	list, err := fofm.NewFileStore(path).List()
+	if err != nil || len(list) != 20 {
+		t.Errorf(`expected 20 records got %v -- %v`, len(list), err)
+	}
+}
+
+func TestFileStoreRemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.json")
+	lock := path + ".lock"
+
+	err := os.WriteFile(lock, []byte("1\n"), 0644)
+	if err != nil {
+		t.Fatalf(`unable to write lock -- %v`, err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(lock, old, old)
+
+	err = fofm.NewFileStore(path).Save(fofm.Migration{Name: "Migration_1_up"}, nil)
+	if err != nil {
+		t.Errorf(`expected the stale lock to be removed -- %v`, err)
+	}
+
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Errorf(`expected the lock to be released`)
+	}
+}
+
+func TestFileStoreUnlockKeepsLockTakenOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.json")
+	first, second := fofm.NewFileStore(path), fofm.NewFileStore(path)
+
+	err := first.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	// the first store stalls for longer than the second one waits
+	time.Sleep(20 * time.Millisecond)
+
+	err = second.Lock(context.Background(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf(`expected the stale lock to be taken over -- %v`, err)
+	}
+
+	err = first.Unlock()
+	if err == nil {
+		t.Errorf(`expected unlocking a lock that was taken over to fail`)
+	}
+
+	if _, err := os.Stat(path + ".migrate.lock"); err != nil {
+		t.Fatalf(`expected the second store's lock to be kept -- %v`, err)
+	}
+
+	err = second.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+
+	if _, err := os.Stat(path + ".migrate.lock"); !os.IsNotExist(err) {
+		t.Errorf(`expected the lock to be released`)
+	}
+}
+
+func TestFileStoreStaleTakeoverIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.json")
+	lock := path + ".migrate.lock"
+
+	err := os.WriteFile(lock, []byte("crashed\n"), 0644)
+	if err != nil {
+		t.Fatalf(`unable to write lock -- %v`, err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(lock, old, old)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	holders, most := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			store := fofm.NewFileStore(path)
+			err := store.Lock(context.Background(), time.Minute)
+			if err != nil {
+				t.Errorf(`unable to lock -- %v`, err)
+				return
+			}
+
+			mu.Lock()
+			holders++
+			most = max(most, holders)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+
+			err = store.Unlock()
+			if err != nil {
+				t.Errorf(`unable to unlock -- %v`, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if most != 1 {
+		t.Errorf(`expected one holder at a time got %v`, most)
+	}
+}
+
+func TestSQLiteLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.db")
+	first, err := fofm.NewSQLite(path)
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	second, err := fofm.NewSQLite(path)
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	err = first.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create store -- %v`, err)
+	}
+
+	testStoreLock(t, first, second)
+}
+
+func TestSQLiteLockReturnsInsertErrors(t *testing.T) {
+	db, err := fofm.NewSQLite(filepath.Join(t.TempDir(), "migrations.db"))
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	// without CreateStore there is no lock table to insert into
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.Lock(ctx, 0)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(`expected the insert error to be returned immediately got %v`, err)
+	}
+}
+
+func TestFileStoreLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.json")
+	testStoreLock(t, fofm.NewFileStore(path), fofm.NewFileStore(path))
+}
+
+// testStoreLock checks that two stores sharing the same storage exclude each
+// other and that stale locks are taken over
+func testStoreLock(t *testing.T, first, second fofm.Locker) {
+	t.Helper()
+
+	err := first.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = second.Lock(ctx, 0)
+	if err == nil {
+		t.Fatalf(`the second store should not acquire a held lock`)
+	}
+
+	if !strings.Contains(err.Error(), fmt.Sprintf(`%d`, os.Getpid())) || !strings.Contains(err.Error(), " ago") {
+		t.Errorf(`expected the error to report the lock's holder and age got %v`, err)
+	}
+
+	err = first.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+
+	err = second.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock after the lock was released -- %v`, err)
+	}
+
+	// the second store "crashes" without unlocking
+	time.Sleep(20 * time.Millisecond)
+
+	err = first.Lock(context.Background(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf(`expected the stale lock to be taken over -- %v`, err)
+	}
+
+	first.Unlock()
+}
@@ -0,0 +1,180 @@
+package fofm_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is a pure Go database/sql driver that records every statement
+// and returns canned rows. It is used to test stores whose databases are not
+// available in the test environment
+type fakeDriver struct {
+	mu      sync.Mutex
+	execs   []fakeStatement
+	queries []fakeStatement
+	columns []string
+	rows    [][]driver.Value
+	matches []fakeMatch
+}
+
+// fakeMatch are the rows returned by the queries that contain match
+type fakeMatch struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+}
+
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+func newFakeDB() (*sql.DB, *fakeDriver) {
+	fd := &fakeDriver{}
+
+	return sql.OpenDB(fd), fd
+}
+
+// setRows sets the rows returned by every following query
+func (d *fakeDriver) setRows(columns []string, rows ...[]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.columns = columns
+	d.rows = rows
+}
+
+// setRowsFor sets the rows returned by the following queries that contain
+// match, instead of the rows set with setRows
+func (d *fakeDriver) setRowsFor(match string, columns []string, rows ...[]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.matches = append(d.matches, fakeMatch{match: match, columns: columns, rows: rows})
+}
+
+func (d *fakeDriver) lastExec() fakeStatement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.execs) == 0 {
+		return fakeStatement{}
+	}
+
+	return d.execs[len(d.execs)-1]
+}
+
+func (d *fakeDriver) lastQuery() fakeStatement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.queries) == 0 {
+		return fakeStatement{}
+	}
+
+	return d.queries[len(d.queries)-1]
+}
+
+// driver.Connector
+
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+// driver.Driver
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+
+	c.driver.execs = append(c.driver.execs, fakeStatement{query: query, args: values(args)})
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+
+	c.driver.queries = append(c.driver.queries, fakeStatement{query: query, args: values(args)})
+
+	for _, match := range c.driver.matches {
+		if strings.Contains(query, match.match) {
+			return &fakeRows{columns: match.columns, rows: match.rows}, nil
+		}
+	}
+
+	return &fakeRows{columns: c.driver.columns, rows: c.driver.rows}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := []driver.Value{}
+	for _, arg := range args {
+		vals = append(vals, arg.Value)
+	}
+
+	return vals
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.pos])
+	r.pos++
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package fofm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// fileStoreLockTimeout is how long a FileStore write will wait for
+	// another process to finish its write
+	fileStoreLockTimeout = 10 * time.Second
+
+	// fileStoreStaleLock is when a FileStore lock is considered abandoned.
+	// writes only hold the lock long enough to rewrite the file
+	fileStoreStaleLock = time.Minute
+)
+
+// NewFileStore creates a store that keeps its records in a JSON file at
+// path. A lock file, path.lock, is used to keep multiple processes from
+// writing to the file at the same time. The migration lock is held with
+// another lock file, path.migrate.lock
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+		lock: lockFile{
+			path:       path + ".lock",
+			staleAfter: fileStoreStaleLock,
+		},
+	}
+}
+
+// FileStore is a Store for projects without a database. Every write
+// replaces the file atomically by writing a temporary file and renaming it
+type FileStore struct {
+	_             struct{}
+	path          string
+	lock          lockFile
+	migrationLock *lockFile
+	lockOwner     string
+}
+
+// fileStoreData is the content of the file
+type fileStoreData struct {
+	LastID     int          `json:"last_id"`
+	Migrations MigrationSet `json:"migrations"`
+}
+
+func (s *FileStore) Connect() error {
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) CreateStore() error {
+	_, err := os.Stat(s.path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return s.update(func(data *fileStoreData) {})
+}
+
+// Lock holds the migration lock file. The lock file is considered stale
+// once it is older than staleAfter
+func (s *FileStore) Lock(ctx context.Context, staleAfter time.Duration) error {
+	lock := lockFile{
+		path:       s.path + ".migrate.lock",
+		staleAfter: staleAfter,
+	}
+
+	owner, err := lock.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.migrationLock = &lock
+	s.lockOwner = owner
+
+	return nil
+}
+
+func (s *FileStore) Unlock() error {
+	if s.migrationLock == nil {
+		return nil
+	}
+
+	lock := s.migrationLock
+	s.migrationLock = nil
+
+	return lock.release(s.lockOwner)
+}
+
+func (s *FileStore) ClearStore() error {
+	return s.update(func(data *fileStoreData) {
+		data.Migrations = MigrationSet{}
+	})
+}
+
+func (s *FileStore) LastRun() (*Migration, error) {
+	return s.last(func(mig Migration) bool {
+		return true
+	})
+}
+
+func (s *FileStore) LastStatusRun(status string) (*Migration, error) {
+	return s.last(func(mig Migration) bool {
+		return mig.Status == status
+	})
+}
+
+func (s *FileStore) LastRunByName(name string) (*Migration, error) {
+	return s.last(func(mig Migration) bool {
+		return mig.Name == name
+	})
+}
+
+func (s *FileStore) List() (MigrationSet, error) {
+	data, err := s.read()
+	if err != nil {
+		return MigrationSet{}, err
+	}
+
+	return data.Migrations, nil
+}
+
+func (s *FileStore) GetAllByName(name string) (MigrationSet, error) {
+	migs := MigrationSet{}
+	data, err := s.read()
+	if err != nil {
+		return migs, err
+	}
+
+	for _, mig := range data.Migrations {
+		if mig.Name == name {
+			migs = append(migs, mig)
+		}
+	}
+
+	return migs, nil
+}
+
+func (s *FileStore) Save(current Migration, err error) error {
+	current.Created = time.Now().UTC()
+	current.Error = ""
+	if err != nil {
+		current.Error = err.Error()
+	}
+
+	return s.update(func(data *fileStoreData) {
+		data.LastID++
+		current.ID = data.LastID
+		data.Migrations = append(data.Migrations, current)
+	})
+}
+
+func (s *FileStore) last(match func(mig Migration) bool) (*Migration, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(data.Migrations) - 1; i >= 0; i-- {
+		if match(data.Migrations[i]) {
+			mig := data.Migrations[i]
+			return &mig, nil
+		}
+	}
+
+	return nil, NoResultsError{OriginalError: ErrNoResults}
+}
+
+// read loads the file. A missing file is the same as an empty one. Reads
+// do not need the lock since the file is always replaced atomically
+func (s *FileStore) read() (fileStoreData, error) {
+	data := fileStoreData{
+		Migrations: MigrationSet{},
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return data, nil
+		}
+
+		return data, err
+	}
+
+	err = json.Unmarshal(content, &data)
+	if data.Migrations == nil {
+		data.Migrations = MigrationSet{}
+	}
+
+	return data, err
+}
+
+// update reads, modifies and atomically rewrites the file while holding the
+// lock
+func (s *FileStore) update(modify func(data *fileStoreData)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), fileStoreLockTimeout)
+	defer cancel()
+
+	owner, err := s.lock.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer s.lock.release(owner)
+
+	data, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	modify(&data)
+
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, content)
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and
+// renames it over path so that readers never see a partial write
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	if closeErr != nil {
+		return closeErr
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
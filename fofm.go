@@ -1,7 +1,13 @@
 package fofm
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
@@ -16,6 +22,7 @@ const (
 	migration_prefix = "Migration"
 	STATUS_SUCCESS   = "success"
 	STATUS_FAILURE   = "failure"
+	STATUS_CANCELED  = "canceled"
 )
 
 type FunctionalMigration interface {
@@ -29,6 +36,8 @@ type FunctionalMigration interface {
 }
 
 // BaseMigration provides an embed struct to easily adhere to the FunctionalMigration interface
+// GetMigrationsPath can only see its caller, so FOFM finds the migrations
+// directory from the methods defined on the embedding type instead
 type BaseMigration struct{}
 
 func (b BaseMigration) GetMigrationsPath() string {
@@ -39,6 +48,54 @@ func (b BaseMigration) GetMigrationsPath() string {
 	return strings.Join(parts[0:len(parts)-1], "/")
 }
 
+// migrationsPath returns the directory of the migration source. When the
+// FunctionalMigration embeds BaseMigration, whose GetMigrationsPath can only
+// see its own caller, it is the directory of a method defined on the type
+func (m *FOFM) migrationsPath() string {
+	path := m.Migration.GetMigrationsPath()
+	if !embedsBaseMigration(m.Migration) {
+		return path
+	}
+
+	typ := reflect.TypeOf(m.Migration)
+	for i := 0; i < typ.NumMethod(); i++ {
+		fn := runtime.FuncForPC(typ.Method(i).Func.Pointer())
+		if fn == nil {
+			continue
+		}
+
+		// promoted methods are wrappers without a source file
+		file, _ := fn.FileLine(fn.Entry())
+		if file != "" && !strings.HasPrefix(file, "<") {
+			return filepath.Dir(file)
+		}
+	}
+
+	return path
+}
+
+// embedsBaseMigration reports whether migration is a struct that embeds
+// BaseMigration
+func embedsBaseMigration(migration FunctionalMigration) bool {
+	typ := reflect.TypeOf(migration)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && (field.Type == reflect.TypeOf(BaseMigration{}) || field.Type == reflect.TypeOf(&BaseMigration{})) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // New will creae a new instance of FOFM. It will apply the DefaultSettings which
 // can be overwritten by passing in settings
 func New(db Store, migrationInstance FunctionalMigration, settings ...Setting) (*FOFM, error) {
@@ -47,6 +104,8 @@ func New(db Store, migrationInstance FunctionalMigration, settings ...Setting) (
 		Migration:          migrationInstance,
 		UpMigrations:       MigrationStack{},
 		DownMigrations:     MigrationStack{},
+		migrations:         map[string]migrationFunc{},
+		checksums:          map[string]string{},
 		migrationStuctName: reflect.TypeOf(migrationInstance).Name(),
 	}
 
@@ -68,14 +127,31 @@ func New(db Store, migrationInstance FunctionalMigration, settings ...Setting) (
 }
 
 type FOFM struct {
-	_                  struct{}
-	DB                 Store
-	Migration          FunctionalMigration
-	UpMigrations       MigrationStack
-	DownMigrations     MigrationStack
-	migrationStuctName string
-	Seeded             bool
-	Writer             WriteFile
+	_                   struct{}
+	DB                  Store
+	Migration           FunctionalMigration
+	UpMigrations        MigrationStack
+	DownMigrations      MigrationStack
+	migrations          map[string]migrationFunc
+	checksums           map[string]string
+	migrationStuctName  string
+	Seeded              bool
+	Writer              WriteFile
+	SQLDB               *sql.DB
+	SQLBackslashEscapes bool
+	LockTimeout         time.Duration
+	StaleLockAfter      time.Duration
+	OutOfOrder          string
+	Warn                func(Warning)
+	Logger              *slog.Logger
+	Host                string
+	Version             string
+	Operator            string
+	Tracer              Tracer
+	beforeAll           []func(ctx context.Context) error
+	afterAll            []func(ctx context.Context, summary RunSummary)
+	beforeEach          []func(ctx context.Context, mig Migration) error
+	afterEach           []func(ctx context.Context, mig Migration, err error)
 }
 
 func (f *FOFM) init() error {
@@ -88,6 +164,8 @@ func (f *FOFM) init() error {
 		ins = ins.Elem()
 	}
 
+	val := reflect.ValueOf(f.Migration)
+
 	for i := 0; i < ins.NumMethod(); i++ {
 		method := ins.Method(i)
 		name := method.Name
@@ -96,11 +174,44 @@ func (f *FOFM) init() error {
 			continue
 		}
 
-		switch direction {
-		case up:
-			f.UpMigrations.Add(name, direction, mTime)
-		case down:
-			f.DownMigrations.Add(name, direction, mTime)
+		if f.stack(direction).HasTimestamp(mTime) {
+			return fmt.Errorf(`migration %v has the same id as another %v migration`, name, direction)
+		}
+
+		fn, err := toMigrationFunc(name, val.MethodByName(name))
+		if err != nil {
+			return err
+		}
+
+		if fn.tx != nil && f.SQLDB == nil {
+			return fmt.Errorf(`migration %v requires a transaction, but no *sql.DB was provided. use the WithSQLDB setting`, name)
+		}
+
+		fn.source = SOURCE_METHOD
+		f.migrations[name] = fn
+		f.stack(direction).Add(name, direction, mTime)
+		f.logDiscovered(SOURCE_METHOD, name)
+	}
+
+	// checksums are only available when the source is, for example they are
+	// not when a compiled binary is deployed without it, or when they were
+	// passed to WithChecksums. Validate reports the migrations missing one
+	if path := f.migrationsPath(); path != "" && len(f.checksums) == 0 {
+		checksums, err := MigrationChecksums(path, f.migrationStuctName)
+		for name, checksum := range checksums {
+			f.checksums[name] = checksum
+		}
+
+		if err != nil {
+			f.logger().LogAttrs(context.Background(), slog.LevelWarn, "checksums unavailable",
+				slog.String(LOG_KEY_ERROR, err.Error()),
+			)
+		}
+	}
+
+	for _, stack := range []MigrationStack{f.UpMigrations, f.DownMigrations} {
+		for i := range stack {
+			stack[i].Checksum = f.checksums[stack[i].Name]
 		}
 	}
 
@@ -114,39 +225,110 @@ func (f *FOFM) init() error {
 
 	f.Seeded = true
 
+	f.logger().LogAttrs(context.Background(), slog.LevelInfo, "migrations discovered",
+		slog.Int(LOG_KEY_COUNT, len(f.UpMigrations)+len(f.DownMigrations)),
+		slog.String(LOG_KEY_SOURCE, SOURCE_METHOD),
+	)
+
+	return nil
+}
+
+// Register adds a migration that is not a method on the FunctionalMigration.
+// This allows migrations to live in any package, be built from closures or be
+// generated. Registered migrations are ordered along with the discovered ones
+// by their id, which takes the place of the unix time in a method name.
+// downFn may be nil when the migration cannot be reverted
+func (m *FOFM) Register(id int64, description string, upFn, downFn func(context.Context) error) error {
+	if upFn == nil {
+		return fmt.Errorf(`migration %v must have an up function`, id)
+	}
+
+	funcs := map[string]migrationFunc{
+		up: {call: upFn},
+	}
+	if downFn != nil {
+		funcs[down] = migrationFunc{call: downFn}
+	}
+
+	return m.register(SOURCE_REGISTER, id, description, funcs, nil)
+}
+
+// register adds the migration funcs, keyed by direction, for id to the
+// stacks. checksums, also keyed by direction, is optional. source is where
+// the migration came from
+func (m *FOFM) register(source string, id int64, description string, funcs map[string]migrationFunc, checksums map[string]string) error {
+	timestamp := time.Unix(id, 0)
+	for direction := range funcs {
+		if m.stack(direction).HasTimestamp(timestamp) {
+			return fmt.Errorf(`a %v migration with the id %v is already defined`, direction, id)
+		}
+	}
+
+	for direction, fn := range funcs {
+		name := MigrationName(id, description, direction)
+		fn.source = source
+		m.migrations[name] = fn
+		if checksum := checksums[direction]; checksum != "" {
+			m.checksums[name] = checksum
+		}
+
+		stack := m.stack(direction)
+		stack.Add(name, direction, timestamp)
+		stack.Last().Checksum = m.checksums[name]
+		m.logDiscovered(source, name)
+	}
+
+	m.UpMigrations.Order()
+	m.DownMigrations.Reverse()
+
 	return nil
 }
 
+// stack returns the stack for the direction
+func (m *FOFM) stack(direction string) *MigrationStack {
+	if direction == down {
+		return &m.DownMigrations
+	}
+
+	return &m.UpMigrations
+}
+
 func (m *FOFM) ClearStore() error {
 	return m.DB.ClearStore()
 }
 
-// GetNextMigrationTemplate will return a migration template and its unix time
-func (m *FOFM) GetNextMigrationTemplate() (string, int64) {
+// GetNextMigrationTemplate will return a migration template and its unix time.
+// The description, which can be empty, is added to the method names
+func (m *FOFM) GetNextMigrationTemplate(description string) (string, int64) {
 	now := time.Now().Unix()
 	sName := m.migrationStuctName
 	template := fmt.Sprintf(`package %s
 	
-func (i %s) Migration_%v_up() error {
+func (i %s) %s() error {
 	// up migration here
 	return nil
 }
 
-func (i %s) Migration_%v_down() error {
+func (i %s) %s() error {
 	// down migration here
 	return nil
 }
-`, m.Migration.GetPackageName(), sName, now, sName, now)
+`, m.Migration.GetPackageName(), sName, MigrationName(now, description, up), sName, MigrationName(now, description, down))
 
 	return template, now
 }
 
 // CreateMigration will create a new migration template based on the current unix time
-// and it will call the defined Writer (which is a file writer by default)
-func (m *FOFM) CreateMigration() (string, error) {
-	template, now := m.GetNextMigrationTemplate()
+// and it will call the defined Writer (which is a file writer by default). The
+// description, which can be empty, is added to the file and method names
+func (m *FOFM) CreateMigration(description string) (string, error) {
+	template, now := m.GetNextMigrationTemplate(description)
 	fileName := fmt.Sprintf(`migration_%v.go`, now)
-	fullPath := fmt.Sprintf(`%s/%s`, m.Migration.GetMigrationsPath(), fileName)
+	if slug := descriptionSlug(description); slug != "" {
+		fileName = fmt.Sprintf(`migration_%v_%s.go`, now, slug)
+	}
+
+	fullPath := fmt.Sprintf(`%s/%s`, m.migrationsPath(), fileName)
 	b := []byte(template)
 	err := m.Writer(fullPath, b, 0644)
 
@@ -157,139 +339,409 @@ func (m *FOFM) CreateMigration() (string, error) {
 	return fullPath, nil
 }
 
-// Status returns a list of all migrations and all of the times when they've been run
-// since migrations can be run mulitple times
+// Status returns a list of all migrations, their applied state and all of
+// the times when they've been run since migrations can be run mulitple times
 func (m *FOFM) Status() (MigrationSetStatus, error) {
 	status := MigrationSetStatus{}
 
-	for _, mig := range m.UpMigrations {
-		all, err := m.DB.GetAllByName(mig.Name)
-		if err != nil {
-			return status, err
-		}
+	list, err := m.DB.List()
+	if err != nil {
+		return status, err
+	}
 
+	runs := map[string]MigrationSet{}
+	for _, run := range list {
+		runs[run.Name] = append(runs[run.Name], run)
+	}
+
+	for _, state := range m.foldState(list).Migrations {
 		status.Migrations = append(status.Migrations, Status{
-			Migration: mig,
-			Runs:      all.ToRuns(),
+			Migration: state.Migration,
+			State:     state.State,
+			Runs:      runs[state.Migration.Name].ToRuns(),
 		})
 	}
 
 	return status, nil
 }
 
-func (m *FOFM) run(names ...string) error {
+// operate holds the lock while it runs the plan selected by planFn. All of it
+// happens inside of the operation's span
+func (m *FOFM) operate(ctx context.Context, operation string, planFn func() (Plan, error)) (err error) {
+	ctx, span := m.tracer().StartOperation(ctx, operation)
+	defer func() {
+		span.End(operationStatus(ctx, err), err)
+	}()
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	plan, err := planFn()
+	if err != nil {
+		return err
+	}
+
+	return m.runPlan(ctx, operation, plan)
+}
+
+// runPlan logs the plan that the operation selected, emits its warnings and
+// runs it
+func (m *FOFM) runPlan(ctx context.Context, operation string, plan Plan) error {
+	m.logPlan(ctx, operation, plan)
+
+	for _, warning := range plan.Warnings {
+		m.warn(ctx, warning)
+	}
+
+	return m.run(ctx, plan.Stack().Names()...)
+}
+
+// run runs the named migrations in order, stopping at the first error. The
+// BeforeAll and AfterAll hooks are called around them when there is at least
+// one to run
+func (m *FOFM) run(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	for _, hook := range m.beforeAll {
+		err := hook(ctx)
+		if err != nil {
+			return fmt.Errorf(`before all hook -- %w`, err)
+		}
+	}
+
+	summary := RunSummary{
+		Started:    time.Now().UTC(),
+		Migrations: MigrationSet{},
+	}
+
 	for _, name := range names {
 		if strings.TrimSpace(name) == "" {
-			return nil
+			break
 		}
 
-		_, direction, err := MigrationNameParts(name)
-		if err != nil {
-			return err
+		mig, err := m.runOne(ctx, name)
+		if mig != nil {
+			summary.Migrations = append(summary.Migrations, *mig)
 		}
 
-		ret := reflect.ValueOf(m.Migration).MethodByName(name).Call([]reflect.Value{})
-		err, _ = ret[0].Interface().(error)
-		mig := Migration{
-			Name:      name,
-			Status:    STATUS_SUCCESS,
-			Timestamp: time.Now().UTC(),
-			Direction: direction,
+		if err != nil {
+			summary.Err = err
+			break
 		}
+	}
 
+	summary.Duration = time.Since(summary.Started)
+	for _, hook := range m.afterAll {
+		hook(ctx, summary)
+	}
+
+	return summary.Err
+}
+
+// runOne runs and records a single migration inside of its span. The returned
+// migration is the run, even when the Store was unable to record it, and is
+// nil when the migration could not be found
+func (m *FOFM) runOne(ctx context.Context, name string) (_ *Migration, err error) {
+	_, direction, err := MigrationNameParts(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, ok := m.migrations[name]
+	if !ok {
+		return nil, fmt.Errorf(`unknown migration: %v`, name)
+	}
+
+	// Status is left empty until the outcome is known
+	mig := Migration{
+		Name:      name,
+		Timestamp: time.Now().UTC(),
+		Direction: direction,
+		Checksum:  m.checksums[name],
+		Host:      m.Host,
+		PID:       os.Getpid(),
+		Version:   m.Version,
+		Operator:  m.Operator,
+	}
+
+	ctx, span := m.tracer().StartMigration(ctx, mig)
+	defer func() {
+		span.End(mig.Status, err)
+	}()
+
+	// stop before starting the next migration if the context is done
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err := fmt.Errorf(`canceled before running: %v -- %w`, name, ctxErr)
+		mig.Status = STATUS_CANCELED
+		m.logFinished(ctx, mig, 0, err)
+		m.save(ctx, mig, err)
+		m.runAfterEach(ctx, mig, err)
+
+		return &mig, err
+	}
+
+	for _, hook := range m.beforeEach {
+		err = hook(ctx, mig)
 		if err != nil {
-			err := fmt.Errorf(`error running: %v -- %v`, name, err)
+			err = fmt.Errorf(`before each hook aborted: %v -- %w`, name, err)
 			mig.Status = STATUS_FAILURE
-			m.DB.Save(mig, err)
+			m.logFinished(ctx, mig, 0, err)
+			m.save(ctx, mig, err)
+			m.runAfterEach(ctx, mig, err)
 
-			return err
+			return &mig, err
 		}
+	}
+
+	m.logger().LogAttrs(ctx, slog.LevelInfo, "migration started",
+		slog.String(LOG_KEY_MIGRATION, name),
+		slog.String(LOG_KEY_DIRECTION, direction),
+	)
+
+	started := time.Now()
 
-		err = m.DB.Save(mig, nil)
+	var saved bool
+	if fn.tx != nil {
+		saved, err = m.runTx(ctx, fn.tx, mig, started)
+	} else {
+		err = fn.call(ctx)
+	}
+
+	duration := time.Since(started)
+	mig.Duration = duration
+
+	if err != nil {
+		mig.Status = STATUS_FAILURE
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			mig.Status = STATUS_CANCELED
+			if !errors.Is(err, ctxErr) {
+				err = fmt.Errorf(`%v -- %w`, err, ctxErr)
+			}
+		}
+
+		err := fmt.Errorf(`error running: %v -- %w`, name, err)
+		m.logFinished(ctx, mig, duration, err)
+		m.save(ctx, mig, err)
+		m.runAfterEach(ctx, mig, err)
+
+		return &mig, err
+	}
+
+	mig.Status = STATUS_SUCCESS
+
+	// the migration ran but, since it was not recorded, it will be run again
+	// so it is reported as a failure
+	if !saved {
+		err = m.save(ctx, mig, nil)
 		if err != nil {
-			return err
+			err = fmt.Errorf(`unable to record run: %v -- %w`, name, err)
+			mig.Status = STATUS_FAILURE
+			m.logFinished(ctx, mig, duration, err)
+			m.runAfterEach(ctx, mig, err)
+
+			return &mig, err
 		}
 	}
 
-	return nil
+	m.logFinished(ctx, mig, duration, nil)
+	m.runAfterEach(ctx, mig, nil)
+
+	return &mig, nil
 }
 
-// Latest will run all up migrations between the last migration that was run and the
-// latest defined migration. If the last migration was a failure, it will attempt to rerun it
-// if the last run migration is the latest and it was successful, it will return an error. If that
-// migration was a failure, it will attempt to rerun it
-func (m *FOFM) Latest() error {
-	lastRun, err := m.DB.LastRun()
+func (m *FOFM) runAfterEach(ctx context.Context, mig Migration, err error) {
+	for _, hook := range m.afterEach {
+		hook(ctx, mig, err)
+	}
+}
+
+// lock acquires the store's migration lock when the store is a Locker. The
+// returned func releases it
+func (m *FOFM) lock(ctx context.Context) (func(), error) {
+	locker, ok := m.DB.(Locker)
+	if !ok {
+		return func() {}, nil
+	}
+
+	lockCtx := ctx
+	if m.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, m.LockTimeout)
+		defer cancel()
+	}
+
+	err := locker.Lock(lockCtx, m.StaleLockAfter)
 	if err != nil {
-		if _, ok := err.(NoResultsError); !ok {
-			return err
-		}
+		return nil, fmt.Errorf(`%w -- %v`, ErrLockNotAcquired, err)
 	}
 
-	if lastRun != nil {
-		switch lastRun.Status {
-		case STATUS_SUCCESS:
-			// do not run anything if the lastRun is actually the latest migration
-			if last := m.UpMigrations.Last(); last != nil {
-				if lastRun.Name == last.Name {
-					return nil
-				}
-			}
+	return func() {
+		m.logError(ctx, "unable to release the lock", locker.Unlock())
+	}, nil
+}
 
-			// the last run should be the next one after the successful run
-			after := m.UpMigrations.After(lastRun)
-			if len(after) > 1 && lastRun.Is("up") {
-				lastRun = &after[1]
-			} else {
-				lastRun = nil
-			}
+// runTx will run the migration inside of a transaction on the SQLDB. It is
+// committed when the migration returns nil and rolled back otherwise. If the
+// store keeps its records in the same database, the successful run is saved,
+// with its duration from started, in that transaction as well and saved will
+// be true
+func (m *FOFM) runTx(ctx context.Context, fn txMigrationFunc, mig Migration, started time.Time) (saved bool, err error) {
+	tx, err := m.SQLDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
 
-		case STATUS_FAILURE:
-			lastRun, err = m.DB.LastStatusRun(STATUS_SUCCESS)
-			if err != nil {
-				if _, ok := err.(NoResultsError); !ok {
-					return err
-				}
-			}
+	err = fn(ctx, tx)
+	if err != nil {
+		m.logError(ctx, "unable to roll back", tx.Rollback(), slog.String(LOG_KEY_MIGRATION, mig.Name))
+		return false, err
+	}
+
+	if store, ok := m.DB.(TxStore); ok && store.SQLDB() == m.SQLDB {
+		mig.Status = STATUS_SUCCESS
+		mig.Duration = time.Since(started)
+		err = store.SaveTx(tx, mig, nil)
+		if err != nil {
+			m.logError(ctx, "unable to roll back", tx.Rollback(), slog.String(LOG_KEY_MIGRATION, mig.Name))
+			return false, fmt.Errorf(`unable to save migration -- %w`, err)
 		}
+
+		saved = true
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	if saved {
+		m.logger().LogAttrs(ctx, slog.LevelDebug, "run recorded",
+			slog.String(LOG_KEY_MIGRATION, mig.Name),
+			slog.String(LOG_KEY_STATUS, mig.Status),
+		)
 	}
 
-	toRun := m.UpMigrations.After(lastRun)
+	return saved, nil
+}
+
+// Latest runs, in order, every up migration that AppliedState reports as not
+// applied, which includes migrations whose last run failed. Migrations older
+// than an applied one are handled by the OutOfOrder setting. Nothing is run,
+// and no error is returned, when every migration is applied
+func (m *FOFM) Latest() error {
+	return m.LatestContext(context.Background())
+}
 
-	return m.run(toRun.Names()...)
+// LatestContext is Latest with a context. The context is passed to every
+// migration that accepts one and no further migrations are started once it
+// is done
+func (m *FOFM) LatestContext(ctx context.Context) error {
+	return m.operate(ctx, OPERATION_LATEST, m.planLatest)
 }
 
 // UP will run all migrations, in order, up to and inclduing the named one passed in
 func (m *FOFM) Up(name string) error {
-	// ensure that the latest migration with the name arg
-	// was not successful
-	latest, err := m.DB.LastRunByName(name)
-	if err == nil && latest != nil {
-		if latest.Status == STATUS_SUCCESS {
-			// return fmt.Errorf(`migration "%s" latest run was successful. will not run again`, name)
-			return nil
-		}
-	}
-
-	toRun := m.UpMigrations.BeforeName(name)
+	return m.UpContext(context.Background(), name)
+}
 
-	return m.run(toRun.Names()...)
+// UpContext is Up with a context
+func (m *FOFM) UpContext(ctx context.Context, name string) error {
+	return m.operate(ctx, OPERATION_UP, func() (Plan, error) {
+		return m.planUp(name)
+	})
 }
 
 // Down will run all migrations, in reverse order, up to and including the named one
 // passed in
 func (m *FOFM) Down(name string) error {
-	toRun := m.DownMigrations.BeforeName(name)
+	return m.DownContext(context.Background(), name)
+}
+
+// DownContext is Down with a context
+func (m *FOFM) DownContext(ctx context.Context, name string) error {
+	return m.operate(ctx, OPERATION_DOWN, func() (Plan, error) {
+		return m.planDown(name)
+	})
+}
+
+// txMigrationFunc is the normalized form of every transactional migration
+// method signature
+type txMigrationFunc func(ctx context.Context, tx *sql.Tx) error
 
-	return m.run(toRun.Names()...)
+// migrationFunc holds a discovered migration. Only one of call or tx is set
+type migrationFunc struct {
+	call   func(ctx context.Context) error
+	tx     txMigrationFunc
+	source string
+}
+
+// toMigrationFunc wraps a discovered migration method so that it can be
+// called with a context regardless of its signature
+func toMigrationFunc(name string, method reflect.Value) (migrationFunc, error) {
+	switch fn := method.Interface().(type) {
+	case func() error:
+		return migrationFunc{
+			call: func(ctx context.Context) error {
+				return fn()
+			},
+		}, nil
+	case func(context.Context) error:
+		return migrationFunc{call: fn}, nil
+	case func(*sql.Tx) error:
+		return migrationFunc{
+			tx: func(ctx context.Context, tx *sql.Tx) error {
+				return fn(tx)
+			},
+		}, nil
+	case func(context.Context, *sql.Tx) error:
+		return migrationFunc{tx: fn}, nil
+	}
+
+	return migrationFunc{}, fmt.Errorf(`unsupported signature for %v: %v must be one of func() error, func(context.Context) error, func(*sql.Tx) error or func(context.Context, *sql.Tx) error`, name, method.Type())
 }
 
 // utility funcs
 
+// MigrationNameParts parses a migration name in the format of
+// Migration_1658164360_up or, with a description,
+// Migration_1658164360_add_user_email_up
 func MigrationNameParts(name string) (timestamp time.Time, direction string, err error) {
+	timestamp, _, direction, err = parseMigrationName(name)
+
+	return
+}
+
+// MigrationNameDescription returns the description in a migration name with
+// its underscores replaced by spaces. It is empty when there isnt one
+func MigrationNameDescription(name string) string {
+	_, description, _, err := parseMigrationName(name)
+	if err != nil {
+		return ""
+	}
+
+	return description
+}
+
+// MigrationName builds the name of a migration. The description, which can be
+// empty, has everything other than letters and numbers replaced by underscores
+func MigrationName(id int64, description, direction string) string {
+	if slug := descriptionSlug(description); slug != "" {
+		return fmt.Sprintf(`%s_%v_%s_%s`, migration_prefix, id, slug, direction)
+	}
+
+	return fmt.Sprintf(`%s_%v_%s`, migration_prefix, id, direction)
+}
+
+func parseMigrationName(name string) (timestamp time.Time, description, direction string, err error) {
 	parts := strings.Split(name, "_")
-	if len(parts) != 3 {
-		err = fmt.Errorf(`incorrect name: %v must be in the format of Migration_1658164360_up`, name)
+	if len(parts) < 3 {
+		err = fmt.Errorf(`incorrect name: %v must be in the format of Migration_1658164360_up or Migration_1658164360_description_up`, name)
 		return
 	}
 
@@ -298,7 +750,14 @@ func MigrationNameParts(name string) (timestamp time.Time, direction string, err
 		return
 	}
 
-	direction = parts[2]
+	direction = parts[len(parts)-1]
+	if direction != up && direction != down {
+		err = fmt.Errorf(`incorrect name: %v must end with _%v or _%v`, name, up, down)
+		return
+	}
+
+	description = strings.Join(parts[2:len(parts)-1], " ")
+
 	var ts int64
 	ts, err = strconv.ParseInt(parts[1], 10, 64)
 	timestamp = time.Unix(ts, 0)
@@ -306,6 +765,12 @@ func MigrationNameParts(name string) (timestamp time.Time, direction string, err
 	return
 }
 
+var nonSlug = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func descriptionSlug(description string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(description, "_"), "_")
+}
+
 var fileTime = regexp.MustCompile("[\\d]+")
 
 func MigrationFileNameTime(name string) (timestamp time.Time, err error) {
@@ -1,6 +1,8 @@
 package fofm_test
 
 import (
+	"context"
+	"database/sql"
 	"runtime"
 	"strings"
 )
@@ -138,3 +140,103 @@ func (i TestMigrationManagerMultiple) Migration_18_up() error {
 func (i TestMigrationManagerMultiple) Migration_18_down() error {
 	return MigrationDownFunc18()
 }
+
+type TestMigrationManagerContext struct {
+}
+
+func (t TestMigrationManagerContext) GetPackageName() string {
+	return TestPKGNAME
+}
+
+func (t TestMigrationManagerContext) GetMigrationsPath() string {
+	_, curFile, _, _ := runtime.Caller(0)
+	parts := strings.Split(curFile, "/")
+
+	return strings.Join(parts[0:len(parts)-1], "/")
+}
+
+var MigrationContextUpFunc1 = func(ctx context.Context) error {
+	return nil
+}
+
+var MigrationContextUpFunc2 = func(ctx context.Context) error {
+	return nil
+}
+
+func (i TestMigrationManagerContext) Migration_1_up(ctx context.Context) error {
+	return MigrationContextUpFunc1(ctx)
+}
+
+func (i TestMigrationManagerContext) Migration_1_down(ctx context.Context) error {
+	return nil
+}
+
+func (i TestMigrationManagerContext) Migration_2_up(ctx context.Context) error {
+	return MigrationContextUpFunc2(ctx)
+}
+
+func (i TestMigrationManagerContext) Migration_2_down() error {
+	return nil
+}
+
+type TestMigrationManagerBadSignature struct {
+	BaseMigrationNoop
+}
+
+func (i TestMigrationManagerBadSignature) Migration_1_up(count int) error {
+	return nil
+}
+
+type BaseMigrationNoop struct{}
+
+func (b BaseMigrationNoop) GetPackageName() string {
+	return TestPKGNAME
+}
+
+func (b BaseMigrationNoop) GetMigrationsPath() string {
+	return ""
+}
+
+type TestMigrationManagerTx struct {
+	BaseMigrationNoop
+}
+
+var MigrationTxUpFunc2 = func(tx *sql.Tx) error {
+	_, err := tx.Exec(`INSERT INTO tx_test (name) VALUES ('second')`)
+	return err
+}
+
+func (i TestMigrationManagerTx) Migration_1_up(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE tx_test (name TEXT NOT NULL)`)
+	return err
+}
+
+func (i TestMigrationManagerTx) Migration_1_down(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE tx_test`)
+	return err
+}
+
+func (i TestMigrationManagerTx) Migration_2_up(tx *sql.Tx) error {
+	return MigrationTxUpFunc2(tx)
+}
+
+func (i TestMigrationManagerTx) Migration_2_down(tx *sql.Tx) error {
+	_, err := tx.Exec(`DELETE FROM tx_test`)
+	return err
+}
+
+type TestMigrationManagerDescription struct {
+	BaseMigrationNoop
+}
+
+func (i TestMigrationManagerDescription) Migration_1_create_users_up() error {
+	return nil
+}
+
+func (i TestMigrationManagerDescription) Migration_1_create_users_down() error {
+	return nil
+}
+
+func (i TestMigrationManagerDescription) Migration_2_up() error {
+	return nil
+}
@@ -1,10 +1,17 @@
 package fofm_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -97,7 +104,7 @@ func TestCreateMigrationTemplate(t *testing.T) {
 		t.Errorf("expected New but got -- %s", err)
 	}
 
-	out, err := mig.CreateMigration()
+	out, err := mig.CreateMigration("")
 	if err != nil {
 		t.Errorf("expected new migration from template but got -- %s", err)
 	}
@@ -124,6 +131,104 @@ func TestCreateMigrationTemplate(t *testing.T) {
 	}
 }
 
+func TestCreateMigrationWithDescription(t *testing.T) {
+	db := getDB(t)
+
+	var newMigration, newFile string
+	testWriter := func(ins *fofm.FOFM) error {
+		ins.Writer = func(filename string, data []byte, perm fs.FileMode) error {
+			newFile = filename
+			newMigration = string(data)
+			return nil
+		}
+
+		return nil
+	}
+
+	mig, err := fofm.New(db, TestMigrationManager{}, testWriter)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	_, err = mig.CreateMigration("add user's email")
+	if err != nil {
+		t.Fatalf("expected new migration from template but got -- %s", err)
+	}
+
+	mTime, err := fofm.MigrationFileNameTime(newFile)
+	if err != nil {
+		t.Fatalf("unable to call MigrationFileNameTime on %s -- %s", newFile, err)
+	}
+
+	if !strings.HasSuffix(newFile, fmt.Sprintf(`migration_%v_add_user_s_email.go`, mTime.Unix())) {
+		t.Errorf(`expected the description in the file name -- %v`, newFile)
+	}
+
+	for _, expected := range []string{
+		fmt.Sprintf(`Migration_%v_add_user_s_email_up()`, mTime.Unix()),
+		fmt.Sprintf(`Migration_%v_add_user_s_email_down()`, mTime.Unix()),
+	} {
+		if !strings.Contains(newMigration, expected) {
+			t.Errorf(`expcted the template to contain -- %v`, expected)
+		}
+	}
+}
+
+func TestMigrationNameParts(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Migration_1658164360_up":                "",
+		"Migration_1658164360_add_user_email_up": "add user email",
+		"Migration_1658164360_add_down":          "add",
+	} {
+		mTime, _, err := fofm.MigrationNameParts(name)
+		if err != nil || mTime.Unix() != 1658164360 {
+			t.Errorf(`unable to parse %v got %v -- %v`, name, mTime, err)
+		}
+
+		if description := fofm.MigrationNameDescription(name); description != expected {
+			t.Errorf(`expected the description of %v to be %q got %q`, name, expected, description)
+		}
+	}
+
+	for _, name := range []string{"Migration_1658164360", "Migration_abc_up", "Migration_1658164360_sideways", "Other_1658164360_up"} {
+		_, _, err := fofm.MigrationNameParts(name)
+		if err == nil {
+			t.Errorf(`expected %v to be rejected`, name)
+		}
+	}
+}
+
+func TestMigrationDescriptionInStatus(t *testing.T) {
+	db := getDB(t)
+	mig, err := fofm.New(db, TestMigrationManagerDescription{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	status, err := mig.Status()
+	if err != nil {
+		t.Fatalf("unable to build status -- %s", err)
+	}
+
+	if len(status.Migrations) != 2 {
+		t.Fatalf(`expected 2 migrations got %v`, len(status.Migrations))
+	}
+
+	first := status.Migrations[0]
+	if first.Migration.Name != "Migration_1_create_users_up" || first.Migration.Description != "create users" || len(first.Runs) != 1 {
+		t.Errorf(`unexpected status -- %+v`, first)
+	}
+
+	if status.Migrations[1].Migration.Description != "" {
+		t.Errorf(`expected no description got %v`, status.Migrations[1].Migration.Description)
+	}
+}
+
 func TestRunLatestUpMigration(t *testing.T) {
 	db := getDB(t)
 
@@ -189,9 +294,10 @@ func TestRunLatestUpMigrationAfterRunningEarlierMigration(t *testing.T) {
 
 	MigrationUpFunc = MigrationUpFuncOrig
 
+	// Migration_1_up and then the four after it
 	list, err := mig.DB.List()
-	if err != nil || len(list) != 4 {
-		t.Errorf(`the number of migrations in the list is incorrect. expected 4 got %v`, len(list))
+	if err != nil || len(list) != 5 {
+		t.Errorf(`the number of migrations in the list is incorrect. expected 5 got %v`, len(list))
 	}
 }
 
@@ -563,3 +669,1427 @@ func TestMigrationStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestNewShouldRejectUnsupportedMigrationSignatures(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerBadSignature{}
+	_, err := fofm.New(db, tm)
+	if err == nil {
+		t.Errorf("expected New to fail on an unsupported migration signature")
+	}
+}
+
+func TestRunContextMigrations(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerContext{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Errorf("expected New but got -- %s", err)
+	}
+
+	type ctxKey struct{}
+	var got any
+	MigrationContextUpFunc1Orig := MigrationContextUpFunc1
+	MigrationContextUpFunc1 = func(ctx context.Context) error {
+		got = ctx.Value(ctxKey{})
+		return nil
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	err = mig.LatestContext(ctx)
+	if err != nil {
+		t.Errorf("unable to run latest -- %v", err)
+	}
+
+	if got != "value" {
+		t.Errorf(`expected the context to be passed to the migration, got %v`, got)
+	}
+
+	MigrationContextUpFunc1 = MigrationContextUpFunc1Orig
+
+	list, err := mig.DB.List()
+	if err != nil || len(list) != 2 {
+		t.Errorf(`the number of migrations in the list is incorrect. expected 2 got %v`, len(list))
+	}
+
+	err = mig.DownContext(ctx, "Migration_1_down")
+	if err != nil {
+		t.Errorf("unable to run down -- %v", err)
+	}
+}
+
+func TestCanceledContextStopsBeforeNextMigration(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerContext{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Errorf("expected New but got -- %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ranSecond := false
+	MigrationContextUpFunc1Orig := MigrationContextUpFunc1
+	MigrationContextUpFunc2Orig := MigrationContextUpFunc2
+	MigrationContextUpFunc1 = func(ctx context.Context) error {
+		cancel()
+		return nil
+	}
+	MigrationContextUpFunc2 = func(ctx context.Context) error {
+		ranSecond = true
+		return nil
+	}
+
+	err = mig.LatestContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf(`expected a context.Canceled error, got %v`, err)
+	}
+
+	if ranSecond {
+		t.Errorf(`the second migration should not have run`)
+	}
+
+	list, err := mig.DB.List()
+	if err != nil {
+		t.Errorf(`unable to list migrations -- %v`, err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf(`unexpected number of migrations (%v) should be 2`, len(list))
+	}
+
+	if list[0].Status != fofm.STATUS_SUCCESS {
+		t.Errorf(`expected %v but got %v`, fofm.STATUS_SUCCESS, list[0].Status)
+	}
+
+	if list[1].Status != fofm.STATUS_CANCELED || list[1].Name != "Migration_2_up" {
+		t.Errorf(`expected Migration_2_up to be %v but got %v %v`, fofm.STATUS_CANCELED, list[1].Name, list[1].Status)
+	}
+
+	// a canceled migration should be picked up by the next run
+	err = mig.Latest()
+	if err != nil {
+		t.Errorf(`unable to rerun latest -- %v`, err)
+	}
+
+	if !ranSecond {
+		t.Errorf(`the canceled migration did not rerun`)
+	}
+
+	MigrationContextUpFunc1 = MigrationContextUpFunc1Orig
+	MigrationContextUpFunc2 = MigrationContextUpFunc2Orig
+}
+
+func TestMigrationReturningContextErrorIsCanceled(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerContext{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Errorf("expected New but got -- %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	MigrationContextUpFunc1Orig := MigrationContextUpFunc1
+	MigrationContextUpFunc1 = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	err = mig.UpContext(ctx, "Migration_1_up")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(`expected a context.DeadlineExceeded error, got %v`, err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil {
+		t.Errorf(`unable to get last run -- %v`, err)
+	}
+
+	if last.Status != fofm.STATUS_CANCELED {
+		t.Errorf(`expected %v but got %v`, fofm.STATUS_CANCELED, last.Status)
+	}
+
+	MigrationContextUpFunc1 = MigrationContextUpFunc1Orig
+}
+
+func TestTxMigrationsRequireSQLDB(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerTx{}
+	_, err := fofm.New(db, tm)
+	if err == nil {
+		t.Errorf("expected New to fail without WithSQLDB")
+	}
+}
+
+func TestRunTxMigrations(t *testing.T) {
+	db, err := fofm.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	tm := TestMigrationManagerTx{}
+	mig, err := fofm.New(db, tm, fofm.WithSQLDB(db.SQLDB()))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Errorf("unable to run latest -- %v", err)
+	}
+
+	var count int
+	err = db.SQLDB().QueryRow(`SELECT COUNT(*) FROM tx_test`).Scan(&count)
+	if err != nil || count != 1 {
+		t.Errorf(`expected 1 row in tx_test got %v -- %v`, count, err)
+	}
+
+	list, err := mig.DB.List()
+	if err != nil || len(list) != 2 {
+		t.Errorf(`the number of migrations in the list is incorrect. expected 2 got %v`, len(list))
+	}
+
+	err = mig.Down("Migration_1_down")
+	if err != nil {
+		t.Errorf("unable to run down -- %v", err)
+	}
+
+	err = db.SQLDB().QueryRow(`SELECT COUNT(*) FROM tx_test`).Scan(&count)
+	if err == nil {
+		t.Errorf(`expected tx_test to be dropped`)
+	}
+}
+
+func TestFailedTxMigrationIsRolledBack(t *testing.T) {
+	db, err := fofm.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	tm := TestMigrationManagerTx{}
+	mig, err := fofm.New(db, tm, fofm.WithSQLDB(db.SQLDB()))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	MigrationTxUpFunc2Orig := MigrationTxUpFunc2
+	MigrationTxUpFunc2 = func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO tx_test (name) VALUES ('second')`)
+		if err != nil {
+			return err
+		}
+
+		return errors.New("some failure")
+	}
+
+	err = mig.Latest()
+	if err == nil {
+		t.Errorf(`the migration should've returned an error`)
+	}
+
+	MigrationTxUpFunc2 = MigrationTxUpFunc2Orig
+
+	var count int
+	err = db.SQLDB().QueryRow(`SELECT COUNT(*) FROM tx_test`).Scan(&count)
+	if err != nil || count != 0 {
+		t.Errorf(`expected the insert to be rolled back, got %v rows -- %v`, count, err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get last run -- %v`, err)
+	}
+
+	if last.Name != "Migration_2_up" || last.Status != fofm.STATUS_FAILURE {
+		t.Errorf(`expected Migration_2_up to be %v but got %v %v`, fofm.STATUS_FAILURE, last.Name, last.Status)
+	}
+}
+
+func TestConcurrentRunnersOnlyRunMigrationsOnce(t *testing.T) {
+	store := fofm.NewMemoryStore()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mig, err := fofm.New(store, TestMigrationManagerContext{})
+			if err == nil {
+				err = mig.Latest()
+			}
+
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf(`unable to run latest -- %v`, err)
+		}
+	}
+
+	for _, name := range []string{"Migration_1_up", "Migration_2_up"} {
+		all, _ := store.GetAllByName(name)
+		if len(all) != 1 {
+			t.Errorf(`expected %v to run once, it ran %v times`, name, len(all))
+		}
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	store := fofm.NewMemoryStore()
+	mig, err := fofm.New(store, TestMigrationManager{}, fofm.WithLockTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	// another runner holds the lock
+	err = store.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	for name, run := range map[string]func() error{
+		"Latest": mig.Latest,
+		"Up":     func() error { return mig.Up("Migration_1_up") },
+		"Down":   func() error { return mig.Down("Migration_1_down") },
+	} {
+		err = run()
+		if !errors.Is(err, fofm.ErrLockNotAcquired) {
+			t.Errorf(`expected %v to return ErrLockNotAcquired got %v`, name, err)
+		}
+	}
+
+	list, _ := store.List()
+	if len(list) != 0 {
+		t.Errorf(`nothing should have run without the lock, got %v runs`, len(list))
+	}
+
+	store.Unlock()
+
+	err = mig.Latest()
+	if err != nil {
+		t.Errorf(`unable to run latest once the lock was released -- %v`, err)
+	}
+
+	// the lock should be released after a run
+	err = mig.Latest()
+	if err != nil {
+		t.Errorf(`unable to run latest a second time -- %v`, err)
+	}
+}
+
+func TestPlanLatestDoesNotRunMigrations(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	plan, err := mig.PlanLatest()
+	if err != nil {
+		t.Fatalf(`unable to plan latest -- %v`, err)
+	}
+
+	expected := []string{"Migration_1_up", "Migration_5_up", "Migration_10_up", "Migration_15_up", "Migration_18_up"}
+	if strings.Join(plan.Stack().Names(), ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the plan %v got %v`, expected, plan.Stack().Names())
+	}
+
+	for _, step := range plan.Steps {
+		if step.Reason != fofm.REASON_PENDING {
+			t.Errorf(`expected %v to be %v got %v`, step.Migration.Name, fofm.REASON_PENDING, step.Reason)
+		}
+	}
+
+	list, _ := mig.DB.List()
+	if len(list) != 0 {
+		t.Errorf(`planning should not run migrations, got %v runs`, len(list))
+	}
+}
+
+func TestPlanMatchesRun(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Up("Migration_1_up")
+	if err != nil {
+		t.Fatalf("unable to run Migration_1_up -- %v", err)
+	}
+
+	plan, err := mig.PlanLatest()
+	if err != nil {
+		t.Fatalf(`unable to plan latest -- %v`, err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	list, _ := mig.DB.List()
+	ran := []string{}
+	for _, run := range list[1:] {
+		ran = append(ran, run.Name)
+	}
+
+	if strings.Join(plan.Stack().Names(), ",") != strings.Join(ran, ",") {
+		t.Errorf(`the plan %v does not match what ran %v`, plan.Stack().Names(), ran)
+	}
+}
+
+func TestPlanLatestRetriesFailure(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManager{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	MigrationUpFuncOrig := MigrationUpFunc
+	MigrationUpFunc = func() error {
+		return fmt.Errorf("some failure")
+	}
+
+	mig.Latest()
+	MigrationUpFunc = MigrationUpFuncOrig
+
+	plan, err := mig.PlanLatest()
+	if err != nil {
+		t.Fatalf(`unable to plan latest -- %v`, err)
+	}
+
+	if len(plan.Steps) != 1 || plan.Steps[0].Reason != fofm.REASON_RETRY {
+		t.Errorf(`expected Migration_1_up to be retried got %+v`, plan.Steps)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	plan, err = mig.PlanLatest()
+	if err != nil || len(plan.Steps) != 0 {
+		t.Errorf(`expected an empty plan got %+v -- %v`, plan.Steps, err)
+	}
+
+	plan, err = mig.PlanUp("Migration_1_up")
+	if err != nil || len(plan.Steps) != 0 {
+		t.Errorf(`expected an empty plan got %+v -- %v`, plan.Steps, err)
+	}
+}
+
+func TestPlanDown(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	plan, err := mig.PlanDown("Migration_10_down")
+	if err != nil {
+		t.Fatalf(`unable to plan down -- %v`, err)
+	}
+
+	expected := []string{"Migration_18_down", "Migration_15_down", "Migration_10_down"}
+	if strings.Join(plan.Stack().Names(), ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the plan %v got %v`, expected, plan.Stack().Names())
+	}
+
+	for _, step := range plan.Steps {
+		if step.Reason != fofm.REASON_ROLLBACK {
+			t.Errorf(`expected %v to be %v got %v`, step.Migration.Name, fofm.REASON_ROLLBACK, step.Reason)
+		}
+	}
+}
+
+func TestRegisteredMigrationsAreMergedAndOrdered(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	ran := []string{}
+	record := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+
+	err = mig.Register(12, "add user email", record("12 up"), record("12 down"))
+	if err != nil {
+		t.Fatalf(`unable to register migration 12 -- %v`, err)
+	}
+
+	err = mig.Register(3, "no down", record("3 up"), nil)
+	if err != nil {
+		t.Fatalf(`unable to register migration 3 -- %v`, err)
+	}
+
+	expected := []string{"Migration_1_up", "Migration_3_no_down_up", "Migration_5_up", "Migration_10_up", "Migration_12_add_user_email_up", "Migration_15_up", "Migration_18_up"}
+	if strings.Join(mig.UpMigrations.Names(), ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the up migrations %v got %v`, expected, mig.UpMigrations.Names())
+	}
+
+	expected = []string{"Migration_18_down", "Migration_15_down", "Migration_12_add_user_email_down", "Migration_10_down", "Migration_5_down", "Migration_1_down"}
+	if strings.Join(mig.DownMigrations.Names(), ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the down migrations %v got %v`, expected, mig.DownMigrations.Names())
+	}
+
+	if description := mig.UpMigrations[4].Description; description != "add user email" {
+		t.Errorf(`expected the registered description got %v`, description)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	if strings.Join(ran, ",") != "3 up,12 up" {
+		t.Errorf(`expected the registered up migrations to run got %v`, ran)
+	}
+
+	err = mig.Down("Migration_12_add_user_email_down")
+	if err != nil {
+		t.Fatalf("unable to run down -- %v", err)
+	}
+
+	if ran[len(ran)-1] != "12 down" {
+		t.Errorf(`expected the registered down migration to run got %v`, ran)
+	}
+}
+
+func TestRegisterRejectsDuplicateMigrations(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManager{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	noop := func(ctx context.Context) error {
+		return nil
+	}
+
+	err = mig.Register(1, "already a method", noop, noop)
+	if err == nil {
+		t.Errorf(`expected an error when registering a migration that is already defined`)
+	}
+
+	err = mig.Register(2, "missing up", nil, noop)
+	if err == nil {
+		t.Errorf(`expected an error when registering a migration without an up function`)
+	}
+
+	if len(mig.UpMigrations) != 1 || len(mig.DownMigrations) != 1 {
+		t.Errorf(`expected rejected migrations not to be added got %v and %v`, mig.UpMigrations.Names(), mig.DownMigrations.Names())
+	}
+}
+
+func runNames(t *testing.T, mig *fofm.FOFM, from int) []string {
+	list, err := mig.DB.List()
+	if err != nil {
+		t.Fatalf(`unable to list runs -- %v`, err)
+	}
+
+	names := []string{}
+	for _, run := range list[from:] {
+		names = append(names, run.Name)
+	}
+
+	return names
+}
+
+func TestRollback(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	err = mig.Rollback(2)
+	if err != nil {
+		t.Fatalf("unable to roll back -- %v", err)
+	}
+
+	expected := []string{"Migration_18_down", "Migration_15_down"}
+	if got := runNames(t, mig, 5); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	plan, err := mig.PlanRollback(2)
+	if err != nil {
+		t.Fatalf("unable to plan rollback -- %v", err)
+	}
+
+	expected = []string{"Migration_10_down", "Migration_5_down"}
+	if strings.Join(plan.Stack().Names(), ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the plan %v got %v`, expected, plan.Stack().Names())
+	}
+
+	err = mig.Rollback(10)
+	if err != nil {
+		t.Fatalf("unable to roll back -- %v", err)
+	}
+
+	plan, err = mig.PlanRollback(1)
+	if err != nil || len(plan.Steps) != 0 {
+		t.Errorf(`expected everything to be rolled back got %v -- %v`, plan.Stack().Names(), err)
+	}
+
+	err = mig.Rollback(0)
+	if err == nil {
+		t.Errorf(`expected an error when rolling back 0 migrations`)
+	}
+}
+
+func TestRollbackWithoutDownMigration(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManager{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Register(2, "irreversible", func(ctx context.Context) error {
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf(`unable to register migration -- %v`, err)
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	err = mig.Rollback(1)
+	if err == nil {
+		t.Errorf(`expected an error when rolling back a migration without a down migration`)
+	}
+}
+
+func TestRedo(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Redo()
+	if !errors.Is(err, fofm.ErrNothingToRollback) {
+		t.Errorf(`expected %v got %v`, fofm.ErrNothingToRollback, err)
+	}
+
+	err = mig.Up("Migration_10_up")
+	if err != nil {
+		t.Fatalf("unable to run up -- %v", err)
+	}
+
+	err = mig.Redo()
+	if err != nil {
+		t.Fatalf("unable to redo -- %v", err)
+	}
+
+	expected := []string{"Migration_10_down", "Migration_10_up"}
+	if got := runNames(t, mig, 3); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	plan, err := mig.PlanRollback(1)
+	if err != nil || strings.Join(plan.Stack().Names(), ",") != "Migration_10_down" {
+		t.Errorf(`expected Migration_10_up to still be applied got %v -- %v`, plan.Stack().Names(), err)
+	}
+}
+
+func TestGoto(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Goto(10)
+	if err != nil {
+		t.Fatalf("unable to go to 10 -- %v", err)
+	}
+
+	expected := []string{"Migration_1_up", "Migration_5_up", "Migration_10_up"}
+	if got := runNames(t, mig, 0); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	plan, err := mig.PlanGoto(10)
+	if err != nil || len(plan.Steps) != 0 {
+		t.Errorf(`expected nothing to run when already at the target got %v -- %v`, plan.Stack().Names(), err)
+	}
+
+	err = mig.Goto(18)
+	if err != nil {
+		t.Fatalf("unable to go to 18 -- %v", err)
+	}
+
+	err = mig.Goto(5)
+	if err != nil {
+		t.Fatalf("unable to go to 5 -- %v", err)
+	}
+
+	expected = []string{"Migration_18_down", "Migration_15_down", "Migration_10_down"}
+	if got := runNames(t, mig, 5); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	err = mig.Goto(0)
+	if err != nil {
+		t.Fatalf("unable to go to 0 -- %v", err)
+	}
+
+	expected = []string{"Migration_5_down", "Migration_1_down"}
+	if got := runNames(t, mig, 8); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	err = mig.Goto(7)
+	if err == nil {
+		t.Errorf(`expected an error for an unknown target`)
+	}
+}
+
+func TestGotoWithoutDownMigration(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManager{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Register(2, "irreversible", func(ctx context.Context) error {
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf(`unable to register migration -- %v`, err)
+	}
+
+	err = mig.Goto(2)
+	if err != nil {
+		t.Fatalf("unable to go to 2 -- %v", err)
+	}
+
+	_, err = mig.PlanGoto(1)
+	if err == nil {
+		t.Errorf(`expected an error when going past a migration without a down migration`)
+	}
+}
+
+func states(t *testing.T, mig *fofm.FOFM) map[string]string {
+	state, err := mig.AppliedState()
+	if err != nil {
+		t.Fatalf(`unable to get the applied state -- %v`, err)
+	}
+
+	got := map[string]string{}
+	for _, s := range state.Migrations {
+		got[s.Migration.Name] = s.State
+	}
+
+	return got
+}
+
+func TestAppliedState(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	MigrationUpFunc15Orig := MigrationUpFunc15
+	MigrationUpFunc15 = func() error {
+		return errors.New("some failure")
+	}
+
+	mig.Latest()
+	MigrationUpFunc15 = MigrationUpFunc15Orig
+
+	err = mig.Down("Migration_5_down")
+	if err != nil {
+		t.Fatalf("unable to run down -- %v", err)
+	}
+
+	err = mig.Up("Migration_5_up")
+	if err != nil {
+		t.Fatalf("unable to run up -- %v", err)
+	}
+
+	expected := map[string]string{
+		"Migration_1_up":  fofm.STATE_APPLIED,
+		"Migration_5_up":  fofm.STATE_APPLIED,
+		"Migration_10_up": fofm.STATE_NOT_APPLIED,
+		"Migration_15_up": fofm.STATE_FAILED,
+		"Migration_18_up": fofm.STATE_NOT_APPLIED,
+	}
+
+	got := states(t, mig)
+	for name, state := range expected {
+		if got[name] != state {
+			t.Errorf(`expected %v to be %v got %v`, name, state, got[name])
+		}
+	}
+
+	plan, err := mig.PlanLatest()
+	if err != nil {
+		t.Fatalf(`unable to plan latest -- %v`, err)
+	}
+
+	if names := strings.Join(plan.Stack().Names(), ","); names != "Migration_10_up,Migration_15_up,Migration_18_up" {
+		t.Errorf(`expected the migrations that are not applied got %v`, names)
+	}
+
+	if plan.Steps[1].Reason != fofm.REASON_RETRY {
+		t.Errorf(`expected Migration_15_up to be retried got %v`, plan.Steps[1].Reason)
+	}
+
+	status, err := mig.Status()
+	if err != nil {
+		t.Fatalf("unable to build status -- %s", err)
+	}
+
+	if status.Migrations[3].State != fofm.STATE_FAILED {
+		t.Errorf(`expected the status to include the state got %v`, status.Migrations[3].State)
+	}
+}
+
+func TestAppliedStateFollowsRenamedMigrations(t *testing.T) {
+	store := fofm.NewMemoryStore()
+	store.Save(fofm.Migration{Name: "Migration_1_up", Direction: "up", Status: fofm.STATUS_SUCCESS}, nil)
+
+	mig, err := fofm.New(store, TestMigrationManagerDescription{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	got := states(t, mig)
+	if got["Migration_1_create_users_up"] != fofm.STATE_APPLIED {
+		t.Errorf(`expected the renamed migration to be applied got %v`, got)
+	}
+
+	plan, err := mig.PlanLatest()
+	if err != nil || strings.Join(plan.Stack().Names(), ",") != "Migration_2_up" {
+		t.Errorf(`expected only Migration_2_up to be planned got %v -- %v`, plan.Stack().Names(), err)
+	}
+}
+
+func TestUpAndDownAcceptPartialNames(t *testing.T) {
+	db := getDB(t)
+	tm := TestMigrationManagerMultiple{}
+	mig, err := fofm.New(db, tm)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Up("5")
+	if err != nil {
+		t.Fatalf("unable to run up -- %v", err)
+	}
+
+	err = mig.Up("Migration_10")
+	if err != nil {
+		t.Fatalf("unable to run up -- %v", err)
+	}
+
+	expected := []string{"Migration_1_up", "Migration_5_up", "Migration_10_up"}
+	if got := runNames(t, mig, 0); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	// only applied migrations are rolled back
+	err = mig.Down("1")
+	if err != nil {
+		t.Fatalf("unable to run down -- %v", err)
+	}
+
+	expected = []string{"Migration_10_down", "Migration_5_down", "Migration_1_down"}
+	if got := runNames(t, mig, 3); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+
+	err = mig.Up("Migration_100_up")
+	if err == nil {
+		t.Errorf(`expected an error for an unknown migration`)
+	}
+}
+
+func TestOutOfOrderPolicy(t *testing.T) {
+	noop := func(ctx context.Context) error {
+		return nil
+	}
+
+	for _, test := range []struct {
+		name     string
+		setting  fofm.Setting
+		err      bool
+		warnings int
+	}{
+		{"allow", fofm.OutOfOrderAllow, false, 0},
+		{"warn", fofm.OutOfOrderWarn, false, 1},
+		{"error", fofm.OutOfOrderError, true, 0},
+	} {
+		warnings := []fofm.Warning{}
+		handler := fofm.WithWarningHandler(func(warning fofm.Warning) {
+			warnings = append(warnings, warning)
+		})
+
+		mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{}, test.setting, handler)
+		if err != nil {
+			t.Fatalf("expected New but got -- %s", err)
+		}
+
+		err = mig.Up("Migration_10_up")
+		if err != nil {
+			t.Fatalf("%v: unable to run up -- %v", test.name, err)
+		}
+
+		// a migration merged from a branch after 10 was applied
+		err = mig.Register(3, "from a branch", noop, noop)
+		if err != nil {
+			t.Fatalf(`%v: unable to register migration -- %v`, test.name, err)
+		}
+
+		err = mig.Latest()
+		if test.err {
+			if !errors.Is(err, fofm.ErrOutOfOrder) || !strings.Contains(err.Error(), "Migration_3_from_a_branch_up") {
+				t.Errorf(`%v: expected %v listing the migration got %v`, test.name, fofm.ErrOutOfOrder, err)
+			}
+
+			list, _ := mig.DB.List()
+			if len(list) != 3 {
+				t.Errorf(`%v: expected nothing to run got %v runs`, test.name, len(list))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%v: unable to run latest -- %v", test.name, err)
+		}
+
+		expected := []string{"Migration_3_from_a_branch_up", "Migration_15_up", "Migration_18_up"}
+		if got := runNames(t, mig, 3); strings.Join(got, ",") != strings.Join(expected, ",") {
+			t.Errorf(`%v: expected %v to run got %v`, test.name, expected, got)
+		}
+
+		if len(warnings) != test.warnings {
+			t.Fatalf(`%v: expected %v warnings got %v`, test.name, test.warnings, len(warnings))
+		}
+
+		if test.warnings > 0 {
+			warning := warnings[0]
+			if warning.Kind != fofm.WARNING_OUT_OF_ORDER || strings.Join(warning.Migrations.Names(), ",") != "Migration_3_from_a_branch_up" {
+				t.Errorf(`%v: unexpected warning -- %+v`, test.name, warning)
+			}
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	calls := []string{}
+	var summary fofm.RunSummary
+
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{},
+		fofm.WithBeforeAll(func(ctx context.Context) error {
+			calls = append(calls, "before all")
+			return nil
+		}),
+		fofm.WithBeforeEach(func(ctx context.Context, mig fofm.Migration) error {
+			calls = append(calls, "before "+mig.Name)
+			return nil
+		}),
+		fofm.WithAfterEach(func(ctx context.Context, mig fofm.Migration, err error) {
+			calls = append(calls, fmt.Sprintf(`after %v %v %v`, mig.Name, mig.Status, err))
+		}),
+		fofm.WithAfterAll(func(ctx context.Context, s fofm.RunSummary) {
+			calls = append(calls, "after all")
+			summary = s
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Up("Migration_5_up")
+	if err != nil {
+		t.Fatalf("unable to run up -- %v", err)
+	}
+
+	expected := []string{
+		"before all",
+		"before Migration_1_up",
+		"after Migration_1_up success <nil>",
+		"before Migration_5_up",
+		"after Migration_5_up success <nil>",
+		"after all",
+	}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the hooks to be called %v got %v`, expected, calls)
+	}
+
+	if len(summary.Migrations) != 2 || summary.Err != nil || summary.Started.IsZero() {
+		t.Errorf(`unexpected summary -- %+v`, summary)
+	}
+
+	// hooks are not called when there is nothing to run
+	calls = []string{}
+	err = mig.Up("Migration_5_up")
+	if err != nil || len(calls) != 0 {
+		t.Errorf(`expected no hooks to be called got %v -- %v`, calls, err)
+	}
+}
+
+func TestBeforeEachErrorAbortsRun(t *testing.T) {
+	var summary fofm.RunSummary
+	afterEach := []error{}
+
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{},
+		fofm.WithBeforeEach(func(ctx context.Context, mig fofm.Migration) error {
+			if mig.Name == "Migration_10_up" {
+				return errors.New("maintenance mode is unavailable")
+			}
+
+			return nil
+		}),
+		fofm.WithAfterEach(func(ctx context.Context, mig fofm.Migration, err error) {
+			afterEach = append(afterEach, err)
+		}),
+		fofm.WithAfterAll(func(ctx context.Context, s fofm.RunSummary) {
+			summary = s
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err == nil || !strings.Contains(err.Error(), "maintenance mode is unavailable") {
+		t.Errorf(`expected the hook's error got %v`, err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get last run -- %v`, err)
+	}
+
+	if last.Name != "Migration_10_up" || last.Status != fofm.STATUS_FAILURE || !strings.Contains(last.Error, "maintenance mode is unavailable") {
+		t.Errorf(`expected the hook's error to be recorded got %+v`, last)
+	}
+
+	if len(afterEach) != 3 || afterEach[2] == nil {
+		t.Errorf(`expected the after each hook to get the error got %v`, afterEach)
+	}
+
+	if summary.Err == nil || len(summary.Failed()) != 1 || len(summary.Migrations) != 3 {
+		t.Errorf(`unexpected summary -- %+v`, summary)
+	}
+}
+
+func TestBeforeAllErrorAbortsRun(t *testing.T) {
+	afterAll := false
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{},
+		fofm.WithBeforeAll(func(ctx context.Context) error {
+			return errors.New("unable to take a backup")
+		}),
+		fofm.WithAfterAll(func(ctx context.Context, s fofm.RunSummary) {
+			afterAll = true
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err == nil {
+		t.Errorf(`expected the hook's error`)
+	}
+
+	list, _ := mig.DB.List()
+	if len(list) != 0 || afterAll {
+		t.Errorf(`expected nothing to run got %v runs, after all called: %v`, len(list), afterAll)
+	}
+}
+
+// failingSaveStore fails to save runs that were not successful
+type failingSaveStore struct {
+	fofm.Store
+}
+
+func (s failingSaveStore) Save(current fofm.Migration, err error) error {
+	if current.Status != fofm.STATUS_SUCCESS {
+		return errors.New("store is down")
+	}
+
+	return s.Store.Save(current, err)
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	records := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := map[string]any{}
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			t.Fatalf(`unable to decode log record %q -- %v`, line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	MigrationUpFunc5Orig := MigrationUpFunc5
+	MigrationUpFunc5 = func() error {
+		return errors.New("some failure")
+	}
+	defer func() {
+		MigrationUpFunc5 = MigrationUpFunc5Orig
+	}()
+
+	mig, err := fofm.New(failingSaveStore{getDB(t)}, TestMigrationManagerMultiple{}, fofm.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err == nil {
+		t.Fatalf(`expected latest to fail`)
+	}
+
+	found := map[string]map[string]any{}
+	for _, record := range logRecords(t, buf) {
+		key := fmt.Sprintf(`%v %v`, record["msg"], record[fofm.LOG_KEY_MIGRATION])
+		found[key] = record
+	}
+
+	for key, attrs := range map[string]map[string]any{
+		"migration discovered Migration_1_up": {fofm.LOG_KEY_SOURCE: fofm.SOURCE_METHOD, fofm.LOG_KEY_DIRECTION: "up"},
+		"plan selected <nil>":                 {fofm.LOG_KEY_OPERATION: fofm.OPERATION_LATEST},
+		"plan step Migration_1_up":            {fofm.LOG_KEY_REASON: fofm.REASON_PENDING},
+		"migration started Migration_1_up":    {"level": "INFO"},
+		"migration finished Migration_1_up":   {fofm.LOG_KEY_STATUS: fofm.STATUS_SUCCESS, "level": "INFO"},
+		"run recorded Migration_1_up":         {fofm.LOG_KEY_STATUS: fofm.STATUS_SUCCESS},
+		"migration finished Migration_5_up":   {fofm.LOG_KEY_STATUS: fofm.STATUS_FAILURE, "level": "ERROR"},
+		"unable to record run Migration_5_up": {fofm.LOG_KEY_ERROR: "store is down"},
+	} {
+		record, ok := found[key]
+		if !ok {
+			t.Errorf(`expected a %q record -- %v`, key, buf.String())
+			continue
+		}
+
+		for attr, value := range attrs {
+			if record[attr] != value {
+				t.Errorf(`expected %q to have %v=%v got %v`, key, attr, value, record[attr])
+			}
+		}
+	}
+
+	if _, ok := found["migration finished Migration_5_up"][fofm.LOG_KEY_DURATION]; !ok {
+		t.Errorf(`expected the finished record to have a duration`)
+	}
+}
+
+func TestLoggerReceivesWarnings(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{}, fofm.OutOfOrderWarn, fofm.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Up("Migration_10_up")
+	if err != nil {
+		t.Fatalf(`unable to run up -- %v`, err)
+	}
+
+	err = mig.Register(7, "merged late", func(ctx context.Context) error {
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf(`unable to register -- %v`, err)
+	}
+
+	buf.Reset()
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf(`unable to run latest -- %v`, err)
+	}
+
+	for _, record := range logRecords(t, buf) {
+		if record["level"] == "WARN" && record[fofm.LOG_KEY_KIND] == fofm.WARNING_OUT_OF_ORDER {
+			return
+		}
+	}
+
+	t.Errorf(`expected an out of order warning -- %v`, buf.String())
+}
+
+func TestRunMetadata(t *testing.T) {
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{},
+		fofm.WithHost("web-1"),
+		fofm.WithVersion("abc123"),
+		fofm.WithOperator("deploy-bot"),
+	)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	MigrationUpFuncOrig := MigrationUpFunc
+	MigrationUpFunc = func() error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+	defer func() {
+		MigrationUpFunc = MigrationUpFuncOrig
+	}()
+
+	err = mig.Up("Migration_1_up")
+	if err != nil {
+		t.Fatalf(`unable to run up -- %v`, err)
+	}
+
+	last, err := mig.DB.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get the last run -- %v`, err)
+	}
+
+	if last.Duration < 5*time.Millisecond {
+		t.Errorf(`expected the duration to be recorded got %v`, last.Duration)
+	}
+
+	if last.Host != "web-1" || last.PID != os.Getpid() || last.Version != "abc123" || last.Operator != "deploy-bot" {
+		t.Errorf(`unexpected run metadata -- %+v`, last)
+	}
+
+	status, err := mig.Status()
+	if err != nil {
+		t.Fatalf(`unable to get the status -- %v`, err)
+	}
+
+	run := status.Migrations[0].Runs[0]
+	if run.Duration != last.Duration || run.Host != last.Host || run.Operator != last.Operator {
+		t.Errorf(`expected the status runs to have the metadata -- %+v`, run)
+	}
+}
+
+func TestDefaultHost(t *testing.T) {
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	host, _ := os.Hostname()
+	if mig.Host != host {
+		t.Errorf(`expected the host to default to %v got %v`, host, mig.Host)
+	}
+}
+
+type spanKey struct{}
+
+// recordingTracer records every span and puts the name of the current span in
+// the context
+type recordingTracer struct {
+	spans []string
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	name   string
+}
+
+func (s recordingSpan) End(status string, err error) {
+	s.tracer.spans = append(s.tracer.spans, fmt.Sprintf(`end %v %v`, s.name, status))
+}
+
+func (r *recordingTracer) start(ctx context.Context, name string) (context.Context, fofm.Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	r.spans = append(r.spans, fmt.Sprintf(`start %v in %q`, name, parent))
+
+	return context.WithValue(ctx, spanKey{}, name), recordingSpan{r, name}
+}
+
+func (r *recordingTracer) StartOperation(ctx context.Context, operation string) (context.Context, fofm.Span) {
+	return r.start(ctx, operation)
+}
+
+func (r *recordingTracer) StartMigration(ctx context.Context, mig fofm.Migration) (context.Context, fofm.Span) {
+	return r.start(ctx, mig.Name)
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{}, fofm.WithTracer(tracer))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	var seen string
+	err = mig.Register(20, "", func(ctx context.Context) error {
+		seen, _ = ctx.Value(spanKey{}).(string)
+		return errors.New("some failure")
+	}, nil)
+	if err != nil {
+		t.Fatalf(`unable to register -- %v`, err)
+	}
+
+	err = mig.Up("Migration_5_up")
+	if err != nil {
+		t.Fatalf(`unable to run up -- %v`, err)
+	}
+
+	expected := []string{
+		`start up in ""`,
+		`start Migration_1_up in "up"`,
+		`end Migration_1_up success`,
+		`start Migration_5_up in "up"`,
+		`end Migration_5_up success`,
+		`end up success`,
+	}
+	if strings.Join(tracer.spans, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the spans %v got %v`, expected, tracer.spans)
+	}
+
+	tracer.spans = nil
+	err = mig.Latest()
+	if err == nil {
+		t.Fatalf(`expected latest to fail`)
+	}
+
+	if seen != "Migration_20_up" {
+		t.Errorf(`expected the migration to receive the context of its span got %q`, seen)
+	}
+
+	last := tracer.spans[len(tracer.spans)-2:]
+	if last[0] != `end Migration_20_up failure` || last[1] != `end latest failure` {
+		t.Errorf(`expected the failure to end both spans got %v`, tracer.spans)
+	}
+}
+
+// brokenStore fails to save every run
+type brokenStore struct {
+	fofm.Store
+}
+
+func (s brokenStore) Save(current fofm.Migration, err error) error {
+	return errors.New("store is down")
+}
+
+func TestUnrecordedRunIsAFailure(t *testing.T) {
+	tracer := &recordingTracer{}
+	var summary fofm.RunSummary
+	var before, after []string
+
+	mig, err := fofm.New(brokenStore{getDB(t)}, TestMigrationManagerMultiple{},
+		fofm.WithTracer(tracer),
+		fofm.WithBeforeEach(func(ctx context.Context, mig fofm.Migration) error {
+			before = append(before, mig.Status)
+			return nil
+		}),
+		fofm.WithAfterEach(func(ctx context.Context, mig fofm.Migration, err error) {
+			after = append(after, fmt.Sprintf(`%v %v`, mig.Name, mig.Status))
+		}),
+		fofm.WithAfterAll(func(ctx context.Context, s fofm.RunSummary) {
+			summary = s
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Latest()
+	if err == nil || !strings.Contains(err.Error(), "store is down") {
+		t.Fatalf(`expected the store error got %v`, err)
+	}
+
+	if len(before) != 1 || before[0] != "" {
+		t.Errorf(`expected the before each hook to see an empty status got %q`, before)
+	}
+
+	if len(after) != 1 || after[0] != "Migration_1_up "+fofm.STATUS_FAILURE {
+		t.Errorf(`expected the after each hook to see the failure got %v`, after)
+	}
+
+	if len(summary.Migrations) != 1 || summary.Migrations[0].Status != fofm.STATUS_FAILURE {
+		t.Errorf(`expected the summary to have the failed run got %+v`, summary.Migrations)
+	}
+
+	if !strings.Contains(strings.Join(tracer.spans, ","), "end Migration_1_up failure") {
+		t.Errorf(`expected the span to end as a failure got %v`, tracer.spans)
+	}
+}
+
+func TestGotoOutOfOrderPolicy(t *testing.T) {
+	mig, err := fofm.New(getDB(t), TestMigrationManagerMultiple{}, fofm.OutOfOrderError)
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = mig.Up("Migration_10_up")
+	if err != nil {
+		t.Fatalf(`unable to run up -- %v`, err)
+	}
+
+	noop := func(ctx context.Context) error {
+		return nil
+	}
+
+	err = mig.Register(3, "from a branch", noop, noop)
+	if err != nil {
+		t.Fatalf(`unable to register migration -- %v`, err)
+	}
+
+	err = mig.Goto(15)
+	if !errors.Is(err, fofm.ErrOutOfOrder) {
+		t.Errorf(`expected %v when 3 would run after 10 got %v`, fofm.ErrOutOfOrder, err)
+	}
+
+	// 5 and 10 are rolled back first so 3 is in order
+	err = mig.Goto(3)
+	if err != nil {
+		t.Fatalf(`unable to goto 3 -- %v`, err)
+	}
+
+	expected := []string{"Migration_10_down", "Migration_5_down", "Migration_3_from_a_branch_up"}
+	if got := runNames(t, mig, 3); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected %v to run got %v`, expected, got)
+	}
+}
@@ -0,0 +1,522 @@
+// Package storetest provides a conformance suite for fofm.Store
+// implementations. Run it from a test in the package that implements the
+// store:
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.RunStoreSuite(t, func() fofm.Store {
+//			return NewMyStore()
+//		})
+//	}
+//
+// The Locker and TxStore subtests only run when the store implements them
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emehrkay/fofm"
+)
+
+// suite is what RunStoreSuite expects of the store
+type suite struct {
+	_               struct{}
+	locksNeverStale bool
+}
+
+// Option changes what RunStoreSuite expects of the store
+type Option func(s *suite)
+
+// LocksNeverStale is for Lockers whose locks can't outlive the process or
+// session holding them, so they ignore staleAfter and are never taken over
+func LocksNeverStale(s *suite) {
+	s.locksNeverStale = true
+}
+
+// RunStoreSuite exercises every Store method against the behavior that FOFM
+// expects. factory must return a new, empty, store every time that it is
+// called
+func RunStoreSuite(t *testing.T, factory func() fofm.Store, options ...Option) {
+	t.Helper()
+
+	conf := &suite{}
+	for _, option := range options {
+		option(conf)
+	}
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, store fofm.Store)
+	}{
+		{"Connect", testConnect},
+		{"CreateStoreIsRepeatable", testCreateStoreIsRepeatable},
+		{"EmptyStoreReturnsNoResultsError", testEmptyStoreReturnsNoResultsError},
+		{"EmptyStoreListsNothing", testEmptyStoreListsNothing},
+		{"SaveRoundTrip", testSaveRoundTrip},
+		{"SaveWithoutError", testSaveWithoutError},
+		{"LastRunIsLastInserted", testLastRunIsLastInserted},
+		{"LastStatusRun", testLastStatusRun},
+		{"LastRunByName", testLastRunByName},
+		{"ListIsInsertionOrdered", testListIsInsertionOrdered},
+		{"GetAllByName", testGetAllByName},
+		{"ClearStore", testClearStore},
+		{"LockExcludes", testLockExcludes},
+		{"LockTakesOverStaleLock", func(t *testing.T, store fofm.Store) {
+			if conf.locksNeverStale {
+				t.Skip(`the store's locks are never stale`)
+			}
+
+			testLockTakesOverStaleLock(t, store)
+		}},
+		{"SaveTxRollsBack", testSaveTxRollsBack},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := factory()
+			t.Cleanup(func() {
+				store.Close()
+			})
+
+			err := store.CreateStore()
+			if err != nil {
+				t.Fatalf(`unable to create store -- %v`, err)
+			}
+
+			tc.test(t, store)
+		})
+	}
+}
+
+// base is the timestamp used for saved migrations. Stores are only required
+// to keep second precision
+var base = time.Unix(1658164360, 0).UTC()
+
+func migration(name, direction, status string, offset int) fofm.Migration {
+	return fofm.Migration{
+		Name:      name,
+		Direction: direction,
+		Status:    status,
+		Timestamp: base.Add(time.Duration(offset) * time.Second),
+	}
+}
+
+func save(t *testing.T, store fofm.Store, mig fofm.Migration, err error) {
+	t.Helper()
+
+	saveErr := store.Save(mig, err)
+	if saveErr != nil {
+		t.Fatalf(`unable to save %v -- %v`, mig.Name, saveErr)
+	}
+}
+
+func expectNoResults(t *testing.T, method string, mig *fofm.Migration, err error) {
+	t.Helper()
+
+	if _, ok := err.(fofm.NoResultsError); !ok {
+		t.Errorf(`%v should return a fofm.NoResultsError when there are no results, got %v (%T)`, method, err, err)
+	}
+
+	if mig != nil {
+		t.Errorf(`%v should return a nil migration when there are no results, got %+v`, method, mig)
+	}
+}
+
+func expectNames(t *testing.T, method string, set fofm.MigrationSet, names ...string) {
+	t.Helper()
+
+	if len(set) != len(names) {
+		t.Fatalf(`%v returned %v migrations, expected %v`, method, len(set), len(names))
+	}
+
+	for i, name := range names {
+		if set[i].Name != name {
+			t.Errorf(`%v returned %v at position %v, expected %v`, method, set[i].Name, i, name)
+		}
+	}
+}
+
+func testConnect(t *testing.T, store fofm.Store) {
+	err := store.Connect()
+	if err != nil {
+		t.Fatalf(`unable to connect -- %v`, err)
+	}
+
+	// connecting again is harmless and the store is still usable
+	err = store.Connect()
+	if err != nil {
+		t.Fatalf(`calling Connect a second time should not fail -- %v`, err)
+	}
+
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+}
+
+func testCreateStoreIsRepeatable(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+
+	err := store.CreateStore()
+	if err != nil {
+		t.Fatalf(`calling CreateStore a second time should not fail -- %v`, err)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf(`unable to list -- %v`, err)
+	}
+
+	expectNames(t, "List", list, "Migration_1_up")
+}
+
+func testEmptyStoreReturnsNoResultsError(t *testing.T, store fofm.Store) {
+	mig, err := store.LastRun()
+	expectNoResults(t, "LastRun", mig, err)
+
+	mig, err = store.LastStatusRun(fofm.STATUS_SUCCESS)
+	expectNoResults(t, "LastStatusRun", mig, err)
+
+	mig, err = store.LastRunByName("Migration_1_up")
+	expectNoResults(t, "LastRunByName", mig, err)
+
+	// a store with records still has no results for unknown names and statuses
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+
+	mig, err = store.LastStatusRun(fofm.STATUS_FAILURE)
+	expectNoResults(t, "LastStatusRun", mig, err)
+
+	mig, err = store.LastRunByName("Migration_2_up")
+	expectNoResults(t, "LastRunByName", mig, err)
+}
+
+func testEmptyStoreListsNothing(t *testing.T, store fofm.Store) {
+	list, err := store.List()
+	if err != nil {
+		t.Errorf(`List should not fail on an empty store -- %v`, err)
+	}
+
+	if len(list) != 0 {
+		t.Errorf(`List should be empty, got %v`, len(list))
+	}
+
+	all, err := store.GetAllByName("Migration_1_up")
+	if err != nil {
+		t.Errorf(`GetAllByName should not fail on an empty store -- %v`, err)
+	}
+
+	if len(all) != 0 {
+		t.Errorf(`GetAllByName should be empty, got %v`, len(all))
+	}
+}
+
+func testSaveRoundTrip(t *testing.T, store fofm.Store) {
+	mig := migration("Migration_1_up", "up", fofm.STATUS_FAILURE, 0)
+	mig.Checksum = "5d41402abc4b2a76b9719d911017c592"
+	mig.Duration = 1500 * time.Millisecond
+	mig.Host = "web-1"
+	mig.PID = 4242
+	mig.Version = "v1.2.3"
+	mig.Operator = "deploy-bot"
+	save(t, store, mig, errors.New("some failure"))
+
+	last, err := store.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get the last run -- %v`, err)
+	}
+
+	if last.Name != mig.Name {
+		t.Errorf(`expected name %v got %v`, mig.Name, last.Name)
+	}
+
+	if last.Direction != mig.Direction {
+		t.Errorf(`expected direction %v got %v`, mig.Direction, last.Direction)
+	}
+
+	if last.Status != mig.Status {
+		t.Errorf(`expected status %v got %v`, mig.Status, last.Status)
+	}
+
+	if last.Error != "some failure" {
+		t.Errorf(`expected the error text to be saved got %q`, last.Error)
+	}
+
+	if last.Timestamp.Unix() != mig.Timestamp.Unix() {
+		t.Errorf(`expected timestamp %v got %v`, mig.Timestamp, last.Timestamp)
+	}
+
+	if last.Created.IsZero() {
+		t.Errorf(`expected created to be set by the store`)
+	}
+
+	if last.Checksum != mig.Checksum {
+		t.Errorf(`expected checksum %v got %v`, mig.Checksum, last.Checksum)
+	}
+
+	if last.Duration != mig.Duration {
+		t.Errorf(`expected duration %v got %v`, mig.Duration, last.Duration)
+	}
+
+	if last.Host != mig.Host || last.PID != mig.PID || last.Version != mig.Version || last.Operator != mig.Operator {
+		t.Errorf(`expected the run metadata %v %v %v %v got %v %v %v %v`, mig.Host, mig.PID, mig.Version, mig.Operator, last.Host, last.PID, last.Version, last.Operator)
+	}
+}
+
+func testSaveWithoutError(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+
+	last, err := store.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get the last run -- %v`, err)
+	}
+
+	if last.Error != "" {
+		t.Errorf(`expected an empty error got %q`, last.Error)
+	}
+}
+
+func testLastRunIsLastInserted(t *testing.T, store fofm.Store) {
+	// timestamps are deliberately out of order, insertion order wins
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 30), nil)
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_SUCCESS, 20), nil)
+	save(t, store, migration("Migration_2_down", "down", fofm.STATUS_SUCCESS, 10), nil)
+
+	last, err := store.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get the last run -- %v`, err)
+	}
+
+	if last.Name != "Migration_2_down" {
+		t.Errorf(`LastRun should return the last inserted migration, got %v`, last.Name)
+	}
+}
+
+func testLastStatusRun(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_FAILURE, 0), errors.New("first"))
+	save(t, store, migration("Migration_3_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	save(t, store, migration("Migration_4_up", "up", fofm.STATUS_FAILURE, 0), errors.New("second"))
+
+	last, err := store.LastStatusRun(fofm.STATUS_SUCCESS)
+	if err != nil {
+		t.Fatalf(`unable to get the last successful run -- %v`, err)
+	}
+
+	if last.Name != "Migration_3_up" {
+		t.Errorf(`expected Migration_3_up got %v`, last.Name)
+	}
+
+	last, err = store.LastStatusRun(fofm.STATUS_FAILURE)
+	if err != nil {
+		t.Fatalf(`unable to get the last failed run -- %v`, err)
+	}
+
+	if last.Name != "Migration_4_up" || last.Error != "second" {
+		t.Errorf(`expected Migration_4_up with the error "second" got %v %q`, last.Name, last.Error)
+	}
+}
+
+func testLastRunByName(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_FAILURE, 0), errors.New("first"))
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_FAILURE, 0), errors.New("second"))
+
+	last, err := store.LastRunByName("Migration_1_up")
+	if err != nil {
+		t.Fatalf(`unable to get the last run by name -- %v`, err)
+	}
+
+	if last.Name != "Migration_1_up" || last.Status != fofm.STATUS_SUCCESS {
+		t.Errorf(`expected the successful Migration_1_up got %v %v`, last.Name, last.Status)
+	}
+}
+
+func testListIsInsertionOrdered(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_3_up", "up", fofm.STATUS_SUCCESS, 30), nil)
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 10), nil)
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_SUCCESS, 20), nil)
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf(`unable to list -- %v`, err)
+	}
+
+	expectNames(t, "List", list, "Migration_3_up", "Migration_1_up", "Migration_2_up")
+
+	for i := 1; i < len(list); i++ {
+		if list[i].ID <= list[i-1].ID {
+			t.Errorf(`expected increasing ids got %v after %v`, list[i].ID, list[i-1].ID)
+		}
+	}
+}
+
+func testGetAllByName(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_FAILURE, 0), errors.New("first"))
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+
+	all, err := store.GetAllByName("Migration_1_up")
+	if err != nil {
+		t.Fatalf(`unable to get all by name -- %v`, err)
+	}
+
+	expectNames(t, "GetAllByName", all, "Migration_1_up", "Migration_1_up")
+
+	if all[0].Status != fofm.STATUS_FAILURE || all[1].Status != fofm.STATUS_SUCCESS {
+		t.Errorf(`GetAllByName should be in insertion order, got %v then %v`, all[0].Status, all[1].Status)
+	}
+}
+
+func testClearStore(t *testing.T, store fofm.Store) {
+	save(t, store, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	save(t, store, migration("Migration_2_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+
+	err := store.ClearStore()
+	if err != nil {
+		t.Fatalf(`unable to clear the store -- %v`, err)
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 0 {
+		t.Errorf(`expected an empty store after ClearStore got %v -- %v`, len(list), err)
+	}
+
+	mig, err := store.LastRun()
+	expectNoResults(t, "LastRun", mig, err)
+
+	// the store should still be usable
+	save(t, store, migration("Migration_3_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+
+	last, err := store.LastRun()
+	if err != nil || last.Name != "Migration_3_up" {
+		t.Errorf(`expected Migration_3_up after clearing got %v -- %v`, last, err)
+	}
+}
+
+// locker returns the store as a Locker or skips the test
+func locker(t *testing.T, store fofm.Store) fofm.Locker {
+	t.Helper()
+
+	locker, ok := store.(fofm.Locker)
+	if !ok {
+		t.Skip(`the store is not a fofm.Locker`)
+	}
+
+	return locker
+}
+
+func testLockExcludes(t *testing.T, store fofm.Store) {
+	locker := locker(t, store)
+
+	err := locker.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = locker.Lock(ctx, 0)
+	if err == nil {
+		t.Fatalf(`Lock should not acquire a held lock`)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(`Lock should return the context's error when it times out, got %v`, err)
+	}
+
+	err = locker.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = locker.Lock(ctx, 0)
+	if err != nil {
+		t.Fatalf(`unable to lock after the lock was released -- %v`, err)
+	}
+
+	err = locker.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+}
+
+func testLockTakesOverStaleLock(t *testing.T, store fofm.Store) {
+	locker := locker(t, store)
+
+	err := locker.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	// the holder "crashes" without unlocking
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = locker.Lock(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf(`a lock held for longer than staleAfter should be taken over -- %v`, err)
+	}
+
+	err = locker.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+}
+
+func testSaveTxRollsBack(t *testing.T, store fofm.Store) {
+	txStore, ok := store.(fofm.TxStore)
+	if !ok {
+		t.Skip(`the store is not a fofm.TxStore`)
+	}
+
+	tx, err := txStore.SQLDB().Begin()
+	if err != nil {
+		t.Fatalf(`unable to begin -- %v`, err)
+	}
+
+	err = txStore.SaveTx(tx, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf(`unable to save in the transaction -- %v`, err)
+	}
+
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf(`unable to roll back -- %v`, err)
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 0 {
+		t.Errorf(`a save in a rolled back transaction should not be kept, got %v -- %v`, len(list), err)
+	}
+
+	tx, err = txStore.SQLDB().Begin()
+	if err != nil {
+		t.Fatalf(`unable to begin -- %v`, err)
+	}
+
+	err = txStore.SaveTx(tx, migration("Migration_1_up", "up", fofm.STATUS_SUCCESS, 0), nil)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf(`unable to save in the transaction -- %v`, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf(`unable to commit -- %v`, err)
+	}
+
+	list, err = store.List()
+	if err != nil {
+		t.Fatalf(`unable to list -- %v`, err)
+	}
+
+	expectNames(t, "List", list, "Migration_1_up")
+}
@@ -0,0 +1,67 @@
+package fofm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Goto will migrate to the target id, the integer in a migration's name, in
+// whichever direction is needed. Every up migration with an id at or before
+// the target is applied and every applied migration after it is rolled back,
+// most recent first. A target of 0 rolls back every applied migration. Up
+// migrations older than one that stays applied are subject to the OutOfOrder
+// setting, as they are with Latest
+func (m *FOFM) Goto(target int64) error {
+	return m.GotoContext(context.Background(), target)
+}
+
+// GotoContext is Goto with a context
+func (m *FOFM) GotoContext(ctx context.Context, target int64) error {
+	return m.operate(ctx, OPERATION_GOTO, func() (Plan, error) {
+		return m.planGoto(target)
+	})
+}
+
+// PlanGoto returns the migrations that Goto would run without running them
+func (m *FOFM) PlanGoto(target int64) (Plan, error) {
+	return m.planGoto(target)
+}
+
+func (m *FOFM) planGoto(target int64) (Plan, error) {
+	targetTime := time.Unix(target, 0)
+	if target != 0 && !m.UpMigrations.HasTimestamp(targetTime) {
+		return Plan{}, fmt.Errorf(`there is no migration with the id %v`, target)
+	}
+
+	state, err := m.AppliedState()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan, err := m.planDownStates(state, func(mig Migration) bool {
+		return mig.Timestamp.After(targetTime)
+	})
+	if err != nil {
+		return plan, err
+	}
+
+	states := []MigrationState{}
+	for _, mig := range state.Migrations {
+		if !mig.Migration.Timestamp.After(targetTime) {
+			states = append(states, mig)
+		}
+	}
+
+	// the OutOfOrder policy is applied against what remains applied once the
+	// migrations after the target are rolled back
+	upPlan, err := m.outOfOrderPlan(MigrationSetState{Migrations: states}, planUpStates(states))
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan.Steps = append(plan.Steps, upPlan.Steps...)
+	plan.Warnings = append(plan.Warnings, upPlan.Warnings...)
+
+	return plan, nil
+}
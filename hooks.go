@@ -0,0 +1,30 @@
+package fofm
+
+import "time"
+
+// RunSummary is passed to the AfterAll hooks once a batch of migrations has
+// finished
+type RunSummary struct {
+	_ struct{} `json:"-"`
+
+	// Migrations are the recorded runs in the order that they were run
+	Migrations MigrationSet `json:"migrations"`
+
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+
+	// Err is the error that stopped the batch, if there was one
+	Err error `json:"-"`
+}
+
+// Failed returns the runs that did not succeed
+func (s RunSummary) Failed() MigrationSet {
+	failed := MigrationSet{}
+	for _, mig := range s.Migrations {
+		if mig.Status != STATUS_SUCCESS {
+			failed = append(failed, mig)
+		}
+	}
+
+	return failed
+}
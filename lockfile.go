@@ -0,0 +1,150 @@
+package fofm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const lockFileRetry = 10 * time.Millisecond
+
+// lockFile is an exclusive lock held by creating a file. It works across
+// processes and platforms since it only relies on O_EXCL and rename. The file
+// holds the owner token returned by acquire. A lock file older than
+// staleAfter is assumed to be left over from a crashed process and is taken
+// over. A staleAfter of 0 disables stale lock detection
+type lockFile struct {
+	path       string
+	staleAfter time.Duration
+}
+
+// acquire blocks until the lock is held or the context is done. It returns
+// the owner token that release needs
+func (l lockFile) acquire(ctx context.Context) (string, error) {
+	owner, err := newLockOwner()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		file, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = fmt.Fprintln(file, owner)
+			if err != nil {
+				file.Close()
+				os.Remove(l.path)
+				return "", err
+			}
+
+			return owner, file.Close()
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+
+		if l.stale() {
+			_, err = l.claim(owner, func(info os.FileInfo, held string) bool {
+				return l.isStale(info)
+			})
+			if err != nil {
+				return "", err
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf(`unable to acquire lock %v%v -- %w`, l.path, l.holder(), ctx.Err())
+		case <-time.After(lockFileRetry):
+		}
+	}
+}
+
+func (l lockFile) stale() bool {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false
+	}
+
+	return l.isStale(info)
+}
+
+func (l lockFile) isStale(info os.FileInfo) bool {
+	return l.staleAfter > 0 && time.Since(info.ModTime()) > l.staleAfter
+}
+
+// claim renames the lock file aside, which only one process can do, and
+// removes it when matches reports that it is the expected lock. Checking the
+// file after it was moved means that a lock created after it was checked is
+// never removed by mistake. A lock that doesn't match is put back unless a
+// new lock was created in the meantime
+func (l lockFile) claim(owner string, matches func(info os.FileInfo, held string) bool) (bool, error) {
+	aside := l.path + "." + owner
+	err := os.Rename(l.path, aside)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer os.Remove(aside)
+
+	info, err := os.Stat(aside)
+	if err != nil {
+		return false, err
+	}
+
+	held, err := os.ReadFile(aside)
+	if err != nil {
+		return false, err
+	}
+
+	if matches(info, strings.TrimSpace(string(held))) {
+		return true, nil
+	}
+
+	// a link fails instead of replacing a lock that was created since
+	err = os.Link(aside, l.path)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// holder describes the owner of the lock and how long it has been held so
+// operators can tell whether it is safe to remove the lock file
+func (l lockFile) holder() string {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return ""
+	}
+
+	owner, _ := os.ReadFile(l.path)
+
+	return fmt.Sprintf(`, held by %v, acquired %v ago`, strings.TrimSpace(string(owner)), time.Since(info.ModTime()).Round(time.Second))
+}
+
+// release removes the lock file when it is still held by owner. It returns an
+// error, and leaves the file, when another process took the lock over
+func (l lockFile) release(owner string) error {
+	released, err := l.claim(owner, func(info os.FileInfo, held string) bool {
+		return held == owner
+	})
+	if err != nil || released {
+		return err
+	}
+
+	if _, err := os.Stat(l.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return fmt.Errorf(`lock %v was taken over%v`, l.path, l.holder())
+}
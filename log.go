@@ -0,0 +1,148 @@
+package fofm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// the attribute keys of every record written to the Logger. They are stable
+// so that they can be indexed
+const (
+	LOG_KEY_OPERATION  = "operation"
+	LOG_KEY_MIGRATION  = "migration"
+	LOG_KEY_MIGRATIONS = "migrations"
+	LOG_KEY_DIRECTION  = "direction"
+	LOG_KEY_SOURCE     = "source"
+	LOG_KEY_REASON     = "reason"
+	LOG_KEY_STATUS     = "status"
+	LOG_KEY_DURATION   = "duration"
+	LOG_KEY_COUNT      = "count"
+	LOG_KEY_KIND       = "kind"
+	LOG_KEY_ERROR      = "error"
+)
+
+// the sources that a discovered migration can come from
+const (
+	SOURCE_METHOD   = "method"
+	SOURCE_REGISTER = "register"
+	SOURCE_SQL      = "sql"
+)
+
+// the operations that select and run a plan
+const (
+	OPERATION_LATEST   = "latest"
+	OPERATION_UP       = "up"
+	OPERATION_DOWN     = "down"
+	OPERATION_ROLLBACK = "rollback"
+	OPERATION_REDO     = "redo"
+	OPERATION_GOTO     = "goto"
+)
+
+// discardHandler drops every record. It is used when no Logger is set
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// logger returns the Logger or one that discards everything
+func (m *FOFM) logger() *slog.Logger {
+	if m.Logger == nil {
+		return discardLogger
+	}
+
+	return m.Logger
+}
+
+// logDiscovered writes a record for each migration added to the stacks
+func (m *FOFM) logDiscovered(source string, names ...string) {
+	for _, name := range names {
+		_, direction, _ := MigrationNameParts(name)
+		m.logger().LogAttrs(context.Background(), slog.LevelDebug, "migration discovered",
+			slog.String(LOG_KEY_MIGRATION, name),
+			slog.String(LOG_KEY_DIRECTION, direction),
+			slog.String(LOG_KEY_SOURCE, source),
+		)
+	}
+}
+
+// logPlan writes the plan that the operation selected, one record for the
+// plan and one for each of its steps
+func (m *FOFM) logPlan(ctx context.Context, operation string, plan Plan) {
+	log := m.logger()
+	log.LogAttrs(ctx, slog.LevelInfo, "plan selected",
+		slog.String(LOG_KEY_OPERATION, operation),
+		slog.Int(LOG_KEY_COUNT, len(plan.Steps)),
+		slog.Any(LOG_KEY_MIGRATIONS, plan.Stack().Names()),
+	)
+
+	for _, step := range plan.Steps {
+		log.LogAttrs(ctx, slog.LevelDebug, "plan step",
+			slog.String(LOG_KEY_OPERATION, operation),
+			slog.String(LOG_KEY_MIGRATION, step.Migration.Name),
+			slog.String(LOG_KEY_DIRECTION, step.Migration.Direction),
+			slog.String(LOG_KEY_REASON, step.Reason),
+		)
+	}
+}
+
+// logFinished writes the outcome of a migration. Failures are errors and
+// cancellations are warnings
+func (m *FOFM) logFinished(ctx context.Context, mig Migration, duration time.Duration, err error) {
+	level := slog.LevelInfo
+	switch mig.Status {
+	case STATUS_FAILURE:
+		level = slog.LevelError
+	case STATUS_CANCELED:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String(LOG_KEY_MIGRATION, mig.Name),
+		slog.String(LOG_KEY_DIRECTION, mig.Direction),
+		slog.String(LOG_KEY_STATUS, mig.Status),
+		slog.Duration(LOG_KEY_DURATION, duration),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String(LOG_KEY_ERROR, err.Error()))
+	}
+
+	m.logger().LogAttrs(ctx, level, "migration finished", attrs...)
+}
+
+// save records the run in the Store and logs the write. It is used for
+// every run, including the failed and canceled ones where the error from the
+// Store cannot be returned without hiding the migration's
+func (m *FOFM) save(ctx context.Context, mig Migration, runErr error) error {
+	err := m.DB.Save(mig, runErr)
+	if err != nil {
+		m.logger().LogAttrs(ctx, slog.LevelError, "unable to record run",
+			slog.String(LOG_KEY_MIGRATION, mig.Name),
+			slog.String(LOG_KEY_STATUS, mig.Status),
+			slog.String(LOG_KEY_ERROR, err.Error()),
+		)
+
+		return err
+	}
+
+	m.logger().LogAttrs(ctx, slog.LevelDebug, "run recorded",
+		slog.String(LOG_KEY_MIGRATION, mig.Name),
+		slog.String(LOG_KEY_STATUS, mig.Status),
+	)
+
+	return nil
+}
+
+// logError writes an error that is not returned to the caller
+func (m *FOFM) logError(ctx context.Context, msg string, err error, attrs ...slog.Attr) {
+	if err == nil {
+		return
+	}
+
+	attrs = append(attrs, slog.String(LOG_KEY_ERROR, err.Error()))
+	m.logger().LogAttrs(ctx, slog.LevelError, msg, attrs...)
+}
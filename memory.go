@@ -0,0 +1,166 @@
+package fofm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoResults is the OriginalError of the NoResultsError returned by stores
+// that do not have a no results error of their own
+var ErrNoResults = errors.New("no results")
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		migrations: MigrationSet{},
+		lock:       make(chan struct{}, 1),
+	}
+}
+
+// MemoryStore keeps its records in memory. It is safe for concurrent use and
+// is meant for tests and ephemeral environments
+type MemoryStore struct {
+	_          struct{}
+	mu         sync.RWMutex
+	migrations MigrationSet
+	lastID     int
+	lock       chan struct{}
+}
+
+func (s *MemoryStore) Connect() error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) CreateStore() error {
+	return nil
+}
+
+// Lock holds the in process migration lock. Since the lock can't outlive the
+// process, staleAfter is ignored
+func (s *MemoryStore) Lock(ctx context.Context, staleAfter time.Duration) error {
+	select {
+	case s.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *MemoryStore) Unlock() error {
+	select {
+	case <-s.lock:
+	default:
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ClearStore() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.migrations = MigrationSet{}
+
+	return nil
+}
+
+func (s *MemoryStore) LastRun() (*Migration, error) {
+	return s.last(func(mig Migration) bool {
+		return true
+	})
+}
+
+func (s *MemoryStore) LastStatusRun(status string) (*Migration, error) {
+	return s.last(func(mig Migration) bool {
+		return mig.Status == status
+	})
+}
+
+func (s *MemoryStore) LastRunByName(name string) (*Migration, error) {
+	return s.last(func(mig Migration) bool {
+		return mig.Name == name
+	})
+}
+
+func (s *MemoryStore) List() (MigrationSet, error) {
+	return s.Snapshot(), nil
+}
+
+func (s *MemoryStore) GetAllByName(name string) (MigrationSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	migs := MigrationSet{}
+	for _, mig := range s.migrations {
+		if mig.Name == name {
+			migs = append(migs, mig)
+		}
+	}
+
+	return migs, nil
+}
+
+func (s *MemoryStore) Save(current Migration, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	current.ID = s.lastID
+	current.Created = time.Now().UTC()
+	current.Error = ""
+	if err != nil {
+		current.Error = err.Error()
+	}
+
+	s.migrations = append(s.migrations, current)
+
+	return nil
+}
+
+// Snapshot returns a copy of every saved record in insertion order
+func (s *MemoryStore) Snapshot() MigrationSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	migs := make(MigrationSet, len(s.migrations))
+	copy(migs, s.migrations)
+
+	return migs
+}
+
+// Restore replaces every saved record with a copy of migs, typically one
+// returned from Snapshot. New records will be given ids after the largest
+// restored one
+func (s *MemoryStore) Restore(migs MigrationSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.migrations = make(MigrationSet, len(migs))
+	copy(s.migrations, migs)
+
+	for _, mig := range migs {
+		if mig.ID > s.lastID {
+			s.lastID = mig.ID
+		}
+	}
+}
+
+// last returns the most recently saved record that matches
+func (s *MemoryStore) last(match func(mig Migration) bool) (*Migration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.migrations) - 1; i >= 0; i-- {
+		if match(s.migrations[i]) {
+			mig := s.migrations[i]
+			return &mig, nil
+		}
+	}
+
+	return nil, NoResultsError{OriginalError: ErrNoResults}
+}
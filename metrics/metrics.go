@@ -0,0 +1,244 @@
+// Package metrics exposes the health of a fofm manager in the Prometheus text
+// exposition format using only the standard library. The Collector is added
+// to a manager as a setting and served as an http.Handler:
+//
+//	collector := metrics.New()
+//	manager, _ := fofm.New(store, migrations, collector.Setting)
+//	http.Handle("/metrics", collector)
+//
+// The run counters and the duration histogram count the migrations run by
+// this process. The pending gauge and the last success timestamp are read
+// from the Store on every scrape so that they are correct for every replica
+// and after restarts
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emehrkay/fofm"
+)
+
+// CONTENT_TYPE is the content type of the Prometheus text exposition format
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the duration histogram
+// buckets when none are passed to New
+var DefaultBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600}
+
+// the label values that are always exported, even before anything has run, so
+// that rates can be computed from the first scrape
+var (
+	directions = []string{"up", "down"}
+	statuses   = []string{fofm.STATUS_SUCCESS, fofm.STATUS_FAILURE, fofm.STATUS_CANCELED}
+)
+
+// New creates a Collector. buckets are the upper bounds, in seconds, of the
+// duration histogram buckets and default to DefaultBuckets
+func New(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	c := &Collector{
+		buckets:   buckets,
+		runs:      map[runKey]uint64{},
+		durations: map[string]*histogram{},
+	}
+
+	for _, direction := range directions {
+		c.durations[direction] = newHistogram(len(buckets))
+		for _, status := range statuses {
+			c.runs[runKey{direction, status}] = 0
+		}
+	}
+
+	return c
+}
+
+// Collector counts the migrations run by a manager and serves them, along
+// with the state of the Store, as Prometheus metrics
+type Collector struct {
+	_         struct{}
+	mu        sync.Mutex
+	manager   *fofm.FOFM
+	buckets   []float64
+	runs      map[runKey]uint64
+	durations map[string]*histogram
+}
+
+type runKey struct {
+	direction string
+	status    string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(buckets int) *histogram {
+	return &histogram{counts: make([]uint64, buckets)}
+}
+
+// Setting is a fofm.Setting that adds the Collector's hook to the manager and
+// keeps the manager so that the Store can be read when scraped
+func (c *Collector) Setting(ins *fofm.FOFM) error {
+	c.mu.Lock()
+	c.manager = ins
+	c.mu.Unlock()
+
+	return fofm.WithAfterEach(c.Observe)(ins)
+}
+
+// Observe records a run. It is called by the manager after every migration
+// once Setting was applied
+func (c *Collector) Observe(ctx context.Context, mig fofm.Migration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.runs[runKey{mig.Direction, mig.Status}]++
+
+	// canceled migrations were never started
+	if mig.Status == fofm.STATUS_CANCELED {
+		return
+	}
+
+	hist, ok := c.durations[mig.Direction]
+	if !ok {
+		hist = newHistogram(len(c.buckets))
+		c.durations[mig.Direction] = hist
+	}
+
+	seconds := mig.Duration.Seconds()
+	for i, bound := range c.buckets {
+		if seconds <= bound {
+			hist.counts[i]++
+		}
+	}
+
+	hist.count++
+	hist.sum += seconds
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buf := &bytes.Buffer{}
+	c.write(buf)
+
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	w.Write(buf.Bytes())
+}
+
+// write writes every metric. When the Store cannot be read, the metrics that
+// come from it are replaced by a comment with the error
+func (c *Collector) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	manager := c.manager
+	c.writeRuns(buf)
+	c.writeDurations(buf)
+	c.mu.Unlock()
+
+	if manager == nil {
+		return
+	}
+
+	writeHeader(buf, "fofm_pending_migrations", "gauge", "Up migrations that are not applied.")
+	state, err := manager.AppliedState()
+	if err != nil {
+		fmt.Fprintf(buf, "# unable to read the store: %s\n", comment(err))
+	} else {
+		var pending int
+		for _, mig := range state.Migrations {
+			if !mig.Is(fofm.STATE_APPLIED) {
+				pending++
+			}
+		}
+
+		fmt.Fprintf(buf, "fofm_pending_migrations %d\n", pending)
+	}
+
+	writeHeader(buf, "fofm_last_success_timestamp_seconds", "gauge", "Unix time of the last successful migration run.")
+	last, err := manager.DB.LastStatusRun(fofm.STATUS_SUCCESS)
+	switch err.(type) {
+	case nil:
+		fmt.Fprintf(buf, "fofm_last_success_timestamp_seconds %s\n", formatFloat(float64(last.Timestamp.UnixNano())/1e9))
+	case fofm.NoResultsError:
+		fmt.Fprintf(buf, "fofm_last_success_timestamp_seconds 0\n")
+	default:
+		fmt.Fprintf(buf, "# unable to read the store: %s\n", comment(err))
+	}
+}
+
+func (c *Collector) writeRuns(buf *bytes.Buffer) {
+	writeHeader(buf, "fofm_migrations_total", "counter", "Migrations run by this process by direction and status.")
+
+	keys := []runKey{}
+	for key := range c.runs {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].direction != keys[j].direction {
+			return keys[i].direction < keys[j].direction
+		}
+
+		return keys[i].status < keys[j].status
+	})
+
+	for _, key := range keys {
+		fmt.Fprintf(buf, "fofm_migrations_total{direction=\"%s\",status=\"%s\"} %d\n", label(key.direction), label(key.status), c.runs[key])
+	}
+}
+
+func (c *Collector) writeDurations(buf *bytes.Buffer) {
+	writeHeader(buf, "fofm_migration_duration_seconds", "histogram", "Duration of the migrations run by this process by direction.")
+
+	keys := []string{}
+	for direction := range c.durations {
+		keys = append(keys, direction)
+	}
+
+	sort.Strings(keys)
+
+	for _, direction := range keys {
+		hist := c.durations[direction]
+		for i, bound := range c.buckets {
+			fmt.Fprintf(buf, "fofm_migration_duration_seconds_bucket{direction=\"%s\",le=\"%s\"} %d\n", label(direction), formatFloat(bound), hist.counts[i])
+		}
+
+		fmt.Fprintf(buf, "fofm_migration_duration_seconds_bucket{direction=\"%s\",le=\"+Inf\"} %d\n", label(direction), hist.count)
+		fmt.Fprintf(buf, "fofm_migration_duration_seconds_sum{direction=\"%s\"} %s\n", label(direction), formatFloat(hist.sum))
+		fmt.Fprintf(buf, "fofm_migration_duration_seconds_count{direction=\"%s\"} %d\n", label(direction), hist.count)
+	}
+}
+
+// format helpers
+
+func writeHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func comment(err error) string {
+	return strings.ReplaceAll(err.Error(), "\n", " ")
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
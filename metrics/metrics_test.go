@@ -0,0 +1,128 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emehrkay/fofm"
+	"github.com/emehrkay/fofm/metrics"
+)
+
+type testMigrations struct{}
+
+func (t testMigrations) GetPackageName() string {
+	return "migrations"
+}
+
+func (t testMigrations) GetMigrationsPath() string {
+	return ""
+}
+
+var MigrationUpFunc2 = func() error {
+	return nil
+}
+
+func (t testMigrations) Migration_1_up() error {
+	return nil
+}
+
+func (t testMigrations) Migration_1_down() error {
+	return nil
+}
+
+func (t testMigrations) Migration_2_up() error {
+	return MigrationUpFunc2()
+}
+
+func (t testMigrations) Migration_2_down() error {
+	return nil
+}
+
+func scrape(t *testing.T, collector *metrics.Collector) string {
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Header().Get("Content-Type") != metrics.CONTENT_TYPE {
+		t.Errorf(`expected the content type %v got %v`, metrics.CONTENT_TYPE, rec.Header().Get("Content-Type"))
+	}
+
+	return rec.Body.String()
+}
+
+func expectLines(t *testing.T, body string, lines ...string) {
+	for _, line := range lines {
+		if !strings.Contains(body, "\n"+line+"\n") {
+			t.Errorf(`expected the line %q -- %v`, line, body)
+		}
+	}
+}
+
+func TestCollector(t *testing.T) {
+	collector := metrics.New(0.5, 0.001)
+	manager, err := fofm.New(fofm.NewMemoryStore(), testMigrations{}, collector.Setting)
+	if err != nil {
+		t.Fatalf(`unable to create manager -- %v`, err)
+	}
+
+	body := scrape(t, collector)
+	expectLines(t, body,
+		"# TYPE fofm_migrations_total counter",
+		`fofm_migrations_total{direction="up",status="success"} 0`,
+		"fofm_pending_migrations 2",
+		"fofm_last_success_timestamp_seconds 0",
+	)
+
+	MigrationUpFunc2Orig := MigrationUpFunc2
+	MigrationUpFunc2 = func() error {
+		return errors.New("some failure")
+	}
+
+	err = manager.Latest()
+	MigrationUpFunc2 = MigrationUpFunc2Orig
+	if err == nil {
+		t.Fatalf(`expected latest to fail`)
+	}
+
+	body = scrape(t, collector)
+	expectLines(t, body,
+		`fofm_migrations_total{direction="up",status="success"} 1`,
+		`fofm_migrations_total{direction="up",status="failure"} 1`,
+		`fofm_migrations_total{direction="down",status="success"} 0`,
+		"# TYPE fofm_migration_duration_seconds histogram",
+		`fofm_migration_duration_seconds_bucket{direction="up",le="0.5"} 2`,
+		`fofm_migration_duration_seconds_bucket{direction="up",le="+Inf"} 2`,
+		`fofm_migration_duration_seconds_count{direction="up"} 2`,
+		"fofm_pending_migrations 1",
+	)
+
+	if strings.Contains(body, "fofm_last_success_timestamp_seconds 0\n") {
+		t.Errorf(`expected the last success timestamp to be set -- %v`, body)
+	}
+
+	// buckets are sorted
+	if strings.Index(body, `le="0.001"`) > strings.Index(body, `le="0.5"`) {
+		t.Errorf(`expected the buckets in order -- %v`, body)
+	}
+
+	err = manager.Latest()
+	if err != nil {
+		t.Fatalf(`unable to run latest -- %v`, err)
+	}
+
+	expectLines(t, scrape(t, collector),
+		`fofm_migrations_total{direction="up",status="success"} 2`,
+		"fofm_pending_migrations 0",
+	)
+}
+
+func TestCollectorWithoutManager(t *testing.T) {
+	body := scrape(t, metrics.New())
+
+	if strings.Contains(body, "fofm_pending_migrations") {
+		t.Errorf(`expected no store metrics without a manager -- %v`, body)
+	}
+
+	expectLines(t, body, `fofm_migration_duration_seconds_bucket{direction="down",le="600"} 0`)
+}
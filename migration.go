@@ -2,6 +2,7 @@ package fofm
 
 import (
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,6 +16,11 @@ func (m MigrationSet) ToRuns() []Run {
 		runs = append(runs, Run{
 			Timestamp: mig.Timestamp,
 			Status:    mig.Status,
+			Duration:  mig.Duration,
+			Host:      mig.Host,
+			PID:       mig.PID,
+			Version:   mig.Version,
+			Operator:  mig.Operator,
 		})
 	}
 
@@ -22,13 +28,19 @@ func (m MigrationSet) ToRuns() []Run {
 }
 
 type Run struct {
-	_         struct{}  `json:"-"`
-	Timestamp time.Time `json:"timestamp"`
-	Status    string    `json:"status"`
+	_         struct{}      `json:"-"`
+	Timestamp time.Time     `json:"timestamp"`
+	Status    string        `json:"status"`
+	Duration  time.Duration `json:"duration"`
+	Host      string        `json:"host,omitempty"`
+	PID       int           `json:"pid,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	Operator  string        `json:"operator,omitempty"`
 }
 type Status struct {
 	_         struct{}  `json:"-"`
 	Migration Migration `json:"migration"`
+	State     string    `json:"state"`
 	Runs      []Run     `json:"runs"`
 }
 type MigrationSetStatus struct {
@@ -37,16 +49,32 @@ type MigrationSetStatus struct {
 }
 
 type Migration struct {
-	_         struct{}  `json:"-"`
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Direction string    `json:"direction"`
-	Status    string    `json:"status"`
-	Error     string    `json:"error"`
-	Timestamp time.Time `json:"timestamp"`
-	Created   time.Time `json:"created"`
-}
-
+	_           struct{}  `json:"-"`
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Direction   string    `json:"direction"`
+	Description string    `json:"description,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error"`
+	Timestamp   time.Time `json:"timestamp"`
+	Created     time.Time `json:"created"`
+	Checksum    string    `json:"checksum,omitempty"`
+
+	// Duration is how long the migration took to run. It is 0 when it was
+	// not started, for example when it was canceled
+	Duration time.Duration `json:"duration"`
+
+	// Host, PID, Version and Operator describe who ran the migration. They
+	// are set with the WithHost, WithVersion and WithOperator settings
+	Host     string `json:"host,omitempty"`
+	PID      int    `json:"pid,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Operator string `json:"operator,omitempty"`
+}
+
+// Scan returns the destinations for the id, name, direction, status, error,
+// timestamp and created columns. The columns that were added after them can
+// be NULL in upgraded tables and are left to the Store
 func (m *Migration) Scan() []any {
 	return []any{
 		&m.ID,
@@ -113,14 +141,31 @@ func (m MigrationStack) Last() *Migration {
 
 func (m *MigrationStack) Add(name, direction string, timestamp time.Time) error {
 	*m = append(*m, Migration{
-		Timestamp: timestamp,
-		Name:      name,
-		Direction: direction,
+		Timestamp:   timestamp,
+		Name:        name,
+		Direction:   direction,
+		Description: MigrationNameDescription(name),
 	})
 
 	return nil
 }
 
+// ByTimestamp returns the migration in the stack with the timestamp or nil
+func (m MigrationStack) ByTimestamp(timestamp time.Time) *Migration {
+	for i := range m {
+		if m[i].Timestamp.Equal(timestamp) {
+			return &m[i]
+		}
+	}
+
+	return nil
+}
+
+// HasTimestamp reports whether a migration in the stack has the timestamp
+func (m MigrationStack) HasTimestamp(timestamp time.Time) bool {
+	return m.ByTimestamp(timestamp) != nil
+}
+
 func (m MigrationStack) Order() {
 	sort.Slice(m, func(i, j int) bool {
 		return m[i].Timestamp.Before(m[j].Timestamp)
@@ -133,49 +178,52 @@ func (m MigrationStack) Reverse() {
 	})
 }
 
-func (m MigrationStack) BeforeName(name string) MigrationStack {
-	stack := MigrationStack{}
-
-	if len(m) == 0 {
-		return stack
+// Find returns the migration matching name or nil. name can be the full
+// name, a partial name like Migration_1 or just the id like 1
+func (m MigrationStack) Find(name string) *Migration {
+	i := m.index(name)
+	if i < 0 {
+		return nil
 	}
 
-	var i int
-	var mig Migration
+	return &m[i]
+}
+
+// BeforeName returns the migrations up to and including the one matching
+// name, see Find. It is empty when there isnt a match
+func (m MigrationStack) BeforeName(name string) MigrationStack {
+	return m[:m.index(name)+1]
+}
+
+// index returns the index of the migration matching name or -1
+func (m MigrationStack) index(name string) int {
+	id, idErr := strconv.ParseInt(name, 10, 64)
 
-	for i, mig = range m {
-		if mig.Name == name {
-			break
+	for i, mig := range m {
+		switch {
+		case mig.Name == name,
+			strings.HasPrefix(mig.Name, name+"_"),
+			idErr == nil && mig.Timestamp.Equal(time.Unix(id, 0)):
+			return i
 		}
 	}
 
-	i += 1
-	stack = m[:i]
-
-	return stack
+	return -1
 }
 
+// After returns the migrations whose timestamps are after the after
+// migration's. It is the whole stack when after is nil
 func (m MigrationStack) After(after *Migration) MigrationStack {
 	if after == nil {
 		return m
 	}
 
-	var i int
-	var mig Migration
 	stack := MigrationStack{}
-
-	if len(m) == 0 {
-		return stack
-	}
-
-	for i, mig = range m {
+	for _, mig := range m {
 		if mig.Timestamp.After(after.Timestamp) {
-			break
+			stack = append(stack, mig)
 		}
 	}
 
-	i += 1
-	stack = append(stack, m[:i]...)
-
 	return stack
 }
@@ -0,0 +1,150 @@
+package fofm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NewMySQLWithTableName creates a MySQL/MariaDB store that keeps its records
+// in tablename. The caller is responsible for opening db with the MySQL
+// driver of their choice
+func NewMySQLWithTableName(db *sql.DB, tablename string) *MySQL {
+	store := NewSQLStore(db, MySQLDialect{}, tablename)
+	store.sessionLock = true
+
+	return &MySQL{
+		SQLStore: store,
+	}
+}
+
+func NewMySQL(db *sql.DB) *MySQL {
+	return NewMySQLWithTableName(db, functionalMigrationTableName)
+}
+
+// MySQL is a SQLStore using the MySQLDialect
+type MySQL struct {
+	*SQLStore
+	lockConn *sql.Conn
+}
+
+// Lock uses a named lock, GET_LOCK, instead of the lock table. Since MySQL
+// releases it when the session ends, a crashed runner can never leave it
+// behind and staleAfter is ignored
+func (m *MySQL) Lock(ctx context.Context, staleAfter time.Duration) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 0)`, m.lockName()).Scan(&locked)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		if locked.Valid && locked.Int64 == 1 {
+			m.lockConn = conn
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			holder := m.lockHolder(conn)
+			conn.Close()
+			return fmt.Errorf(`the named lock is held by %v -- %w`, holder, ctx.Err())
+		case <-time.After(sqlLockRetry):
+		}
+	}
+}
+
+func (m *MySQL) Unlock() error {
+	if m.lockConn == nil {
+		return nil
+	}
+
+	conn := m.lockConn
+	m.lockConn = nil
+	defer conn.Close()
+
+	var released sql.NullInt64
+	return conn.QueryRowContext(context.Background(), `SELECT RELEASE_LOCK(?)`, m.lockName()).Scan(&released)
+}
+
+// lockHolder describes the connection holding the named lock so that
+// operators can find, and KILL, it. MySQL doesn't record when a named lock
+// was acquired, so the user and host are added when the process list can be
+// read
+func (m *MySQL) lockHolder(conn *sql.Conn) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var id sql.NullInt64
+	err := conn.QueryRowContext(ctx, `SELECT IS_USED_LOCK(?)`, m.lockName()).Scan(&id)
+	if err != nil || !id.Valid {
+		return "another runner"
+	}
+
+	var user, host string
+	err = conn.QueryRowContext(ctx, `SELECT USER, HOST FROM information_schema.PROCESSLIST WHERE ID = ?`, id.Int64).Scan(&user, &host)
+	if err != nil {
+		return fmt.Sprintf(`connection %v`, id.Int64)
+	}
+
+	return fmt.Sprintf(`connection %v (%v@%v)`, id.Int64, user, host)
+}
+
+// lockName is the name of the lock for the table. MySQL limits lock names to
+// 64 characters
+func (m *MySQL) lockName() string {
+	name := "fofm:" + m.tablename
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
+
+// MySQLDialect stores timestamps in DATETIME(6) columns. Since DATETIME does
+// not keep the time zone, every timestamp is written in UTC
+type MySQLDialect struct{}
+
+func (d MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d MySQLDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (d MySQLDialect) CreateStatements(schema, table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		direction VARCHAR(16) NOT NULL,
+		timestamp DATETIME(6) NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		error TEXT NULL,
+		created DATETIME(6) NOT NULL,
+		checksum VARCHAR(64) NULL,
+		duration BIGINT NULL,
+		host VARCHAR(255) NULL,
+		pid BIGINT NULL,
+		version VARCHAR(255) NULL,
+		operator VARCHAR(255) NULL
+	)`, table),
+	}
+}
+
+func (d MySQLDialect) EncodeTime(t time.Time) any {
+	return t.UTC()
+}
+
+func (d MySQLDialect) DecodeTime(src any) (time.Time, error) {
+	return decodeTime(src)
+}
@@ -0,0 +1,135 @@
+package fofm_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emehrkay/fofm"
+)
+
+func TestMySQLCreateStore(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewMySQLWithTableName(db, "runs")
+
+	err := store.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create store -- %v`, err)
+	}
+
+	table := fd.execs[0].query
+	for _, expected := range []string{"CREATE TABLE IF NOT EXISTS `runs`", `timestamp DATETIME(6) NOT NULL`, `created DATETIME(6) NOT NULL`} {
+		if !strings.Contains(table, expected) {
+			t.Errorf(`expected the create table statement to contain %v -- %v`, expected, table)
+		}
+	}
+
+	// the named lock replaces the lock table
+	for _, exec := range fd.execs {
+		if strings.Contains(exec.query, "runs_lock") {
+			t.Errorf(`expected the lock table not to be created -- %v`, exec.query)
+		}
+	}
+}
+
+func TestMySQLSave(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewMySQL(db)
+	ts := time.Date(2022, 7, 18, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	err := store.Save(fofm.Migration{
+		Name:      "Migration_1_up",
+		Direction: "up",
+		Status:    fofm.STATUS_SUCCESS,
+		Timestamp: ts,
+	}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	stmt := fd.lastExec()
+	if !strings.Contains(stmt.query, `(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`) || strings.Contains(stmt.query, `$1`) {
+		t.Errorf(`expected ? placeholders -- %v`, stmt.query)
+	}
+
+	if got, ok := stmt.args[4].(time.Time); !ok || !got.Equal(ts) || got.Location() != time.UTC {
+		t.Errorf(`expected the timestamp to be passed as a UTC time.Time got %v`, stmt.args[4])
+	}
+}
+
+func TestMySQLLastStatusRunDecodesTextTimestamps(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewMySQL(db)
+
+	// without parseTime=true the driver returns DATETIME columns as text
+	fd.setRows(storeColumns, []driver.Value{int64(1), "Migration_1_up", "up", fofm.STATUS_SUCCESS, "", []byte("2022-07-18 12:00:00.123456"), []byte("2022-07-18 12:00:01.000000"), "", nil, nil, nil, nil, nil})
+
+	mig, err := store.LastStatusRun(fofm.STATUS_SUCCESS)
+	if err != nil {
+		t.Fatalf(`unable to get last run -- %v`, err)
+	}
+
+	stmt := fd.lastQuery()
+	if !strings.Contains(stmt.query, `status = ?`) || stmt.args[0] != fofm.STATUS_SUCCESS {
+		t.Errorf(`unexpected query -- %v %v`, stmt.query, stmt.args)
+	}
+
+	expected := time.Date(2022, 7, 18, 12, 0, 0, 123456000, time.UTC)
+	if !mig.Timestamp.Equal(expected) {
+		t.Errorf(`expected %v got %v`, expected, mig.Timestamp)
+	}
+}
+
+func TestMySQLListNoResults(t *testing.T) {
+	db, _ := newFakeDB()
+	store := fofm.NewMySQL(db)
+
+	migs, err := store.List()
+	if err != nil || len(migs) != 0 {
+		t.Errorf(`expected an empty list got %v -- %v`, len(migs), err)
+	}
+}
+
+func TestMySQLLockUsesNamedLock(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewMySQL(db)
+	fd.setRows([]string{"locked"}, []driver.Value{int64(1)})
+
+	err := store.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	stmt := fd.lastQuery()
+	if !strings.Contains(stmt.query, `GET_LOCK(?, 0)`) || stmt.args[0] != "fofm:function_migrations" {
+		t.Errorf(`expected a named lock -- %v %v`, stmt.query, stmt.args)
+	}
+
+	err = store.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+
+	if !strings.Contains(fd.lastQuery().query, `RELEASE_LOCK(?)`) {
+		t.Errorf(`expected the named lock to be released -- %v`, fd.lastQuery().query)
+	}
+}
+
+func TestMySQLLockNamesHolder(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewMySQL(db)
+	fd.setRows([]string{"locked"}, []driver.Value{int64(0)})
+	fd.setRowsFor("IS_USED_LOCK", []string{"id"}, []driver.Value{int64(42)})
+	fd.setRowsFor("PROCESSLIST", []string{"user", "host"}, []driver.Value{"app", "10.0.0.1:5000"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := store.Lock(ctx, 0)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), `connection 42 (app@10.0.0.1:5000)`) {
+		t.Errorf(`expected the error to name the holder got %v`, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Package otelfofm provides a fofm.Tracer that records OpenTelemetry spans.
+// Latest, Up, Down, Rollback, Redo and Goto each start a span that every
+// migration they run is a child span of:
+//
+//	manager, _ := fofm.New(store, migrations, fofm.WithTracer(otelfofm.New(nil)))
+//
+// It lives in its own module so that the core fofm module does not depend on
+// OpenTelemetry
+package otelfofm
+
+import (
+	"context"
+
+	"github.com/emehrkay/fofm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// INSTRUMENTATION_NAME is the name of the tracer that the spans are recorded by
+const INSTRUMENTATION_NAME = "github.com/emehrkay/fofm/otelfofm"
+
+// the span attribute keys
+const (
+	ATTR_OPERATION = attribute.Key("fofm.operation")
+	ATTR_MIGRATION = attribute.Key("fofm.migration.name")
+	ATTR_DIRECTION = attribute.Key("fofm.migration.direction")
+	ATTR_STATUS    = attribute.Key("fofm.status")
+)
+
+// New creates a Tracer using the provider. When provider is nil the global
+// provider is used
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &Tracer{
+		tracer: provider.Tracer(INSTRUMENTATION_NAME),
+	}
+}
+
+// Tracer is a fofm.Tracer that records OpenTelemetry spans
+type Tracer struct {
+	_      struct{}
+	tracer trace.Tracer
+}
+
+// StartOperation starts the parent span named "fofm <operation>"
+func (t *Tracer) StartOperation(ctx context.Context, operation string) (context.Context, fofm.Span) {
+	ctx, span := t.tracer.Start(ctx, "fofm "+operation,
+		trace.WithAttributes(ATTR_OPERATION.String(operation)),
+	)
+
+	return ctx, Span{span}
+}
+
+// StartMigration starts a child span named after the migration
+func (t *Tracer) StartMigration(ctx context.Context, mig fofm.Migration) (context.Context, fofm.Span) {
+	ctx, span := t.tracer.Start(ctx, mig.Name,
+		trace.WithAttributes(
+			ATTR_MIGRATION.String(mig.Name),
+			ATTR_DIRECTION.String(mig.Direction),
+		),
+	)
+
+	return ctx, Span{span}
+}
+
+// Span is a fofm.Span for an OpenTelemetry span
+type Span struct {
+	span trace.Span
+}
+
+// End sets the status attribute, records the error when there is one and
+// ends the span
+func (s Span) End(status string, err error) {
+	s.span.SetAttributes(ATTR_STATUS.String(status))
+
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	} else {
+		s.span.SetStatus(codes.Ok, "")
+	}
+
+	s.span.End()
+}
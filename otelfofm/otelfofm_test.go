@@ -0,0 +1,105 @@
+package otelfofm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/emehrkay/fofm"
+	"github.com/emehrkay/fofm/otelfofm"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type testMigrations struct{}
+
+func (t testMigrations) GetPackageName() string {
+	return "migrations"
+}
+
+func (t testMigrations) GetMigrationsPath() string {
+	return ""
+}
+
+var MigrationUpFunc2 = func(ctx context.Context) error {
+	return nil
+}
+
+func (t testMigrations) Migration_1_up() error {
+	return nil
+}
+
+func (t testMigrations) Migration_2_up(ctx context.Context) error {
+	return MigrationUpFunc2(ctx)
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.AsString()
+		}
+	}
+
+	return ""
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	manager, err := fofm.New(fofm.NewMemoryStore(), testMigrations{}, fofm.WithTracer(otelfofm.New(provider)))
+	if err != nil {
+		t.Fatalf(`unable to create manager -- %v`, err)
+	}
+
+	var migrationSpan trace.SpanContext
+	MigrationUpFunc2Orig := MigrationUpFunc2
+	MigrationUpFunc2 = func(ctx context.Context) error {
+		migrationSpan = trace.SpanContextFromContext(ctx)
+		return errors.New("some failure")
+	}
+
+	err = manager.Latest()
+	MigrationUpFunc2 = MigrationUpFunc2Orig
+	if err == nil {
+		t.Fatalf(`expected latest to fail`)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf(`expected 3 spans got %v`, len(spans))
+	}
+
+	first, second, parent := spans[0], spans[1], spans[2]
+	if parent.Name() != "fofm latest" || attr(parent, "fofm.operation") != fofm.OPERATION_LATEST {
+		t.Errorf(`unexpected parent span %v %v`, parent.Name(), parent.Attributes())
+	}
+
+	if parent.Status().Code != codes.Error || attr(parent, "fofm.status") != fofm.STATUS_FAILURE {
+		t.Errorf(`expected the parent span to have failed got %v`, parent.Status())
+	}
+
+	for _, span := range []sdktrace.ReadOnlySpan{first, second} {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf(`expected %v to be a child of the operation span`, span.Name())
+		}
+
+		if attr(span, "fofm.migration.name") != span.Name() || attr(span, "fofm.migration.direction") != "up" {
+			t.Errorf(`unexpected attributes on %v -- %v`, span.Name(), span.Attributes())
+		}
+	}
+
+	if first.Name() != "Migration_1_up" || attr(first, "fofm.status") != fofm.STATUS_SUCCESS || first.Status().Code != codes.Ok {
+		t.Errorf(`unexpected first span %v %v`, first.Name(), first.Attributes())
+	}
+
+	if second.Name() != "Migration_2_up" || attr(second, "fofm.status") != fofm.STATUS_FAILURE || len(second.Events()) == 0 {
+		t.Errorf(`expected the second span to record the failure -- %v %v`, second.Name(), second.Events())
+	}
+
+	if migrationSpan.SpanID() != second.SpanContext().SpanID() {
+		t.Errorf(`expected the migration to receive the context of its span`)
+	}
+}
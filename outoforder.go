@@ -0,0 +1,88 @@
+package fofm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	OUT_OF_ORDER_ERROR = "error"
+	OUT_OF_ORDER_ALLOW = "allow"
+	OUT_OF_ORDER_WARN  = "warn"
+
+	WARNING_OUT_OF_ORDER = "out of order"
+)
+
+// ErrOutOfOrder is returned by Latest, Up and Goto when using the OutOfOrderError
+// setting and there are migrations that are older than an applied one
+var ErrOutOfOrder = errors.New("there are migrations older than the last applied migration")
+
+// Warning is emitted, to the Logger and the handler set with
+// WithWarningHandler, when FOFM continues despite something that should be
+// looked at
+type Warning struct {
+	_          struct{}       `json:"-"`
+	Kind       string         `json:"kind"`
+	Message    string         `json:"message"`
+	Migrations MigrationStack `json:"migrations"`
+}
+
+// warn writes the warning to the Logger and sends it to the handler when
+// there is one
+func (m *FOFM) warn(ctx context.Context, warning Warning) {
+	m.logger().LogAttrs(ctx, slog.LevelWarn, warning.Message,
+		slog.String(LOG_KEY_KIND, warning.Kind),
+		slog.Any(LOG_KEY_MIGRATIONS, warning.Migrations.Names()),
+	)
+
+	if m.Warn != nil {
+		m.Warn(warning)
+	}
+}
+
+// outOfOrder returns the migrations in the plan that are older than the
+// newest applied migration
+func outOfOrder(state MigrationSetState, plan Plan) MigrationStack {
+	var newest time.Time
+	for _, mig := range state.Applied() {
+		if mig.Migration.Timestamp.After(newest) {
+			newest = mig.Migration.Timestamp
+		}
+	}
+
+	stack := MigrationStack{}
+	for _, step := range plan.Steps {
+		if step.Migration.Timestamp.Before(newest) {
+			stack = append(stack, step.Migration)
+		}
+	}
+
+	return stack
+}
+
+// outOfOrderPlan applies the OutOfOrder policy to a planned up run
+func (m *FOFM) outOfOrderPlan(state MigrationSetState, plan Plan) (Plan, error) {
+	stack := outOfOrder(state, plan)
+	if len(stack) == 0 {
+		return plan, nil
+	}
+
+	names := strings.Join(stack.Names(), ", ")
+
+	switch m.OutOfOrder {
+	case OUT_OF_ORDER_ERROR:
+		return Plan{}, fmt.Errorf(`%w -- %v`, ErrOutOfOrder, names)
+	case OUT_OF_ORDER_WARN:
+		plan.Warnings = append(plan.Warnings, Warning{
+			Kind:       WARNING_OUT_OF_ORDER,
+			Message:    fmt.Sprintf(`running migrations that are older than the last applied migration: %v`, names),
+			Migrations: stack,
+		})
+	}
+
+	return plan, nil
+}
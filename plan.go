@@ -0,0 +1,146 @@
+package fofm
+
+import "fmt"
+
+const (
+	REASON_PENDING  = "pending"
+	REASON_RETRY    = "retrying failure"
+	REASON_ROLLBACK = "rollback target"
+	REASON_REDO     = "redo"
+)
+
+// PlanStep is a migration that would be run and why it was selected
+type PlanStep struct {
+	_         struct{}  `json:"-"`
+	Migration Migration `json:"migration"`
+	Reason    string    `json:"reason"`
+}
+
+// Plan is the ordered list of migrations that Latest, Up or Down would run
+// and the warnings that running them would emit
+type Plan struct {
+	_        struct{}   `json:"-"`
+	Steps    []PlanStep `json:"steps"`
+	Warnings []Warning  `json:"warnings,omitempty"`
+}
+
+// Stack returns the planned migrations in the order that they would run
+func (p Plan) Stack() MigrationStack {
+	stack := MigrationStack{}
+
+	for _, step := range p.Steps {
+		stack = append(stack, step.Migration)
+	}
+
+	return stack
+}
+
+// PlanLatest returns the migrations that Latest would run without running them
+func (m *FOFM) PlanLatest() (Plan, error) {
+	return m.planLatest()
+}
+
+// PlanUp returns the migrations that Up would run without running them
+func (m *FOFM) PlanUp(name string) (Plan, error) {
+	return m.planUp(name)
+}
+
+// PlanDown returns the migrations that Down would run without running them
+func (m *FOFM) PlanDown(name string) (Plan, error) {
+	return m.planDown(name)
+}
+
+func (m *FOFM) planLatest() (Plan, error) {
+	state, err := m.AppliedState()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return m.outOfOrderPlan(state, planUpStates(state.Migrations))
+}
+
+func (m *FOFM) planUp(name string) (Plan, error) {
+	target := m.UpMigrations.Find(name)
+	if target == nil {
+		return Plan{}, fmt.Errorf(`unknown migration: %v`, name)
+	}
+
+	state, err := m.AppliedState()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	states := []MigrationState{}
+	for _, mig := range state.Migrations {
+		if !mig.Migration.Timestamp.After(target.Timestamp) {
+			states = append(states, mig)
+		}
+	}
+
+	return m.outOfOrderPlan(state, planUpStates(states))
+}
+
+func (m *FOFM) planDown(name string) (Plan, error) {
+	target := m.DownMigrations.Find(name)
+	if target == nil {
+		return Plan{}, fmt.Errorf(`unknown migration: %v`, name)
+	}
+
+	state, err := m.AppliedState()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return m.planDownStates(state, func(mig Migration) bool {
+		return !mig.Timestamp.Before(target.Timestamp)
+	})
+}
+
+// planUpStates selects every migration that is not applied and explains why
+func planUpStates(states []MigrationState) Plan {
+	plan := Plan{}
+
+	for _, state := range states {
+		if state.Is(STATE_APPLIED) {
+			continue
+		}
+
+		reason := REASON_PENDING
+		if last := state.LastRun; last != nil && last.Is(up) && (last.Status == STATUS_FAILURE || last.Status == STATUS_CANCELED) {
+			reason = REASON_RETRY
+		}
+
+		plan.Steps = append(plan.Steps, PlanStep{
+			Migration: state.Migration,
+			Reason:    reason,
+		})
+	}
+
+	return plan
+}
+
+// planDownStates selects, newest first, the down migration of every applied
+// migration that include returns true for. It is an error when one of them
+// does not have a down migration
+func (m *FOFM) planDownStates(state MigrationSetState, include func(mig Migration) bool) (Plan, error) {
+	plan := Plan{}
+
+	for i := len(state.Migrations) - 1; i >= 0; i-- {
+		mig := state.Migrations[i]
+		if !mig.Is(STATE_APPLIED) || !include(mig.Migration) {
+			continue
+		}
+
+		downMig := m.DownMigrations.ByTimestamp(mig.Migration.Timestamp)
+		if downMig == nil {
+			return Plan{}, fmt.Errorf(`%v cannot be rolled back, it does not have a down migration`, mig.Migration.Name)
+		}
+
+		plan.Steps = append(plan.Steps, PlanStep{
+			Migration: *downMig,
+			Reason:    REASON_ROLLBACK,
+		})
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,161 @@
+package fofm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+const postgresDefaultSchema = "public"
+
+// NewPostgresWithTableName creates a Postgres store that keeps its records in
+// schema.tablename. The caller is responsible for opening db with the
+// Postgres driver of their choice
+func NewPostgresWithTableName(db *sql.DB, schema, tablename string) *Postgres {
+	store := NewSQLStoreWithSchema(db, PostgresDialect{}, schema, tablename)
+	store.sessionLock = true
+
+	return &Postgres{
+		SQLStore: store,
+	}
+}
+
+// NewPostgres creates a Postgres store that keeps its records in the default
+// table in the public schema
+func NewPostgres(db *sql.DB) *Postgres {
+	return NewPostgresWithTableName(db, postgresDefaultSchema, functionalMigrationTableName)
+}
+
+// Postgres is a SQLStore using the PostgresDialect
+type Postgres struct {
+	*SQLStore
+	lockConn *sql.Conn
+}
+
+// Lock uses a session level advisory lock instead of the lock table. Since
+// Postgres releases it when the session ends, a crashed runner can never leave
+// it behind and staleAfter is ignored
+func (p *Postgres) Lock(ctx context.Context, staleAfter time.Duration) error {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var locked bool
+		err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, p.lockKey()).Scan(&locked)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		if locked {
+			p.lockConn = conn
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			holder := p.lockHolder(conn)
+			conn.Close()
+			return fmt.Errorf(`the advisory lock is held by %v -- %w`, holder, ctx.Err())
+		case <-time.After(sqlLockRetry):
+		}
+	}
+}
+
+func (p *Postgres) Unlock() error {
+	if p.lockConn == nil {
+		return nil
+	}
+
+	conn := p.lockConn
+	p.lockConn = nil
+	defer conn.Close()
+
+	var unlocked bool
+	return conn.QueryRowContext(context.Background(), `SELECT pg_advisory_unlock($1)`, p.lockKey()).Scan(&unlocked)
+}
+
+// lockHolder describes the session holding the advisory lock so that
+// operators can find it. Postgres doesn't record when an advisory lock was
+// granted, so the age is that of the session
+func (p *Postgres) lockHolder(conn *sql.Conn) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	query := `
+	SELECT
+		a.pid, COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), ''), EXTRACT(EPOCH FROM now() - a.backend_start)
+	FROM
+		pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+	WHERE
+		l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+		AND ((l.classid::bigint << 32) | l.objid::bigint) = $1`
+
+	var pid int64
+	var application, client string
+	var connected float64
+	err := conn.QueryRowContext(ctx, query, p.lockKey()).Scan(&pid, &application, &client, &connected)
+	if err != nil {
+		return "another runner"
+	}
+
+	age := time.Duration(connected * float64(time.Second)).Round(time.Second)
+
+	return fmt.Sprintf(`pid %v (application %q, client %q, connected %v ago)`, pid, application, client, age)
+}
+
+// lockKey is the advisory lock key for the table
+func (p *Postgres) lockKey() int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(p.table()))
+
+	return int64(hash.Sum64())
+}
+
+// PostgresDialect stores timestamps in native timestamptz columns
+type PostgresDialect struct{}
+
+func (d PostgresDialect) Placeholder(n int) string {
+	return dollarPlaceholder(n)
+}
+
+func (d PostgresDialect) QuoteIdentifier(name string) string {
+	return doubleQuote(name)
+}
+
+func (d PostgresDialect) CreateStatements(schema, table string) []string {
+	statements := []string{}
+
+	if schema != "" {
+		statements = append(statements, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, schema))
+	}
+
+	return append(statements, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		direction TEXT NOT NULL,
+		timestamp TIMESTAMPTZ NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT NULL,
+		created TIMESTAMPTZ NOT NULL,
+		checksum TEXT NULL,
+		duration BIGINT NULL,
+		host TEXT NULL,
+		pid BIGINT NULL,
+		version TEXT NULL,
+		operator TEXT NULL
+	)`, table))
+}
+
+func (d PostgresDialect) EncodeTime(t time.Time) any {
+	return t.UTC()
+}
+
+func (d PostgresDialect) DecodeTime(src any) (time.Time, error) {
+	return decodeTime(src)
+}
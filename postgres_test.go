@@ -0,0 +1,195 @@
+package fofm_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emehrkay/fofm"
+)
+
+var storeColumns = []string{"id", "name", "direction", "status", "error", "timestamp", "created", "checksum", "duration", "host", "pid", "version", "operator"}
+
+func TestPostgresCreateStore(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgresWithTableName(db, "fofm", "runs")
+	fd.setRows(storeColumns)
+
+	err := store.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create store -- %v`, err)
+	}
+
+	// the advisory lock replaces the lock table
+	if len(fd.execs) != 2 {
+		t.Fatalf(`expected 2 statements got %v`, len(fd.execs))
+	}
+
+	if !strings.Contains(fd.execs[0].query, `CREATE SCHEMA IF NOT EXISTS "fofm"`) {
+		t.Errorf(`expected the schema to be created -- %v`, fd.execs[0].query)
+	}
+
+	table := fd.execs[1].query
+	for _, expected := range []string{`CREATE TABLE IF NOT EXISTS "fofm"."runs"`, `timestamp TIMESTAMPTZ NOT NULL`, `created TIMESTAMPTZ NOT NULL`} {
+		if !strings.Contains(table, expected) {
+			t.Errorf(`expected the create table statement to contain %v -- %v`, expected, table)
+		}
+	}
+}
+
+func TestPostgresQuotesIdentifiers(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgresWithTableName(db, "", `odd"name`)
+
+	err := store.ClearStore()
+	if err != nil {
+		t.Fatalf(`unable to clear store -- %v`, err)
+	}
+
+	if !strings.Contains(fd.lastExec().query, `DELETE FROM "odd""name"`) {
+		t.Errorf(`expected a quoted table name -- %v`, fd.lastExec().query)
+	}
+}
+
+func TestPostgresSave(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgres(db)
+	ts := time.Date(2022, 7, 18, 12, 0, 0, 0, time.UTC)
+
+	err := store.Save(fofm.Migration{
+		Name:      "Migration_1_up",
+		Direction: "up",
+		Status:    fofm.STATUS_SUCCESS,
+		Timestamp: ts,
+	}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	stmt := fd.lastExec()
+	if !strings.Contains(stmt.query, `"public"."function_migrations"`) || !strings.Contains(stmt.query, `$6`) {
+		t.Errorf(`unexpected insert statement -- %v`, stmt.query)
+	}
+
+	if len(stmt.args) != 12 {
+		t.Fatalf(`expected 12 args got %v`, len(stmt.args))
+	}
+
+	if stmt.args[0] != "Migration_1_up" {
+		t.Errorf(`expected the name as the first arg got %v`, stmt.args[0])
+	}
+
+	if got, ok := stmt.args[4].(time.Time); !ok || !got.Equal(ts) {
+		t.Errorf(`expected the timestamp to be passed as a time.Time got %v`, stmt.args[4])
+	}
+
+	if _, ok := stmt.args[5].(time.Time); !ok {
+		t.Errorf(`expected created to be passed as a time.Time got %v`, stmt.args[5])
+	}
+}
+
+func TestPostgresLastRunNoResults(t *testing.T) {
+	db, _ := newFakeDB()
+	store := fofm.NewPostgres(db)
+
+	_, err := store.LastRun()
+	if _, ok := err.(fofm.NoResultsError); !ok {
+		t.Errorf(`expected a NoResultsError got %v`, err)
+	}
+}
+
+func TestPostgresLastRunByName(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgres(db)
+	ts := time.Date(2022, 7, 18, 12, 0, 0, 0, time.UTC)
+	created := ts.Add(time.Second)
+	fd.setRows(storeColumns, []driver.Value{int64(3), "Migration_1_up", "up", fofm.STATUS_SUCCESS, "", ts, created, "", nil, nil, nil, nil, nil})
+
+	mig, err := store.LastRunByName("Migration_1_up")
+	if err != nil {
+		t.Fatalf(`unable to get last run -- %v`, err)
+	}
+
+	stmt := fd.lastQuery()
+	if !strings.Contains(stmt.query, `name = $1`) || len(stmt.args) != 1 || stmt.args[0] != "Migration_1_up" {
+		t.Errorf(`unexpected query -- %v %v`, stmt.query, stmt.args)
+	}
+
+	if mig.ID != 3 || mig.Name != "Migration_1_up" {
+		t.Errorf(`unexpected migration -- %+v`, mig)
+	}
+
+	if !mig.Timestamp.Equal(ts) || !mig.Created.Equal(created) {
+		t.Errorf(`expected the timestamps to be scanned got %v and %v`, mig.Timestamp, mig.Created)
+	}
+}
+
+func TestPostgresGetAllByName(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgres(db)
+	ts := time.Date(2022, 7, 18, 12, 0, 0, 0, time.UTC)
+	fd.setRows(storeColumns,
+		[]driver.Value{int64(1), "Migration_1_up", "up", fofm.STATUS_FAILURE, "some failure", ts, ts, "", nil, nil, nil, nil, nil},
+		[]driver.Value{int64(2), "Migration_1_up", "up", fofm.STATUS_SUCCESS, "", ts, ts, "", nil, nil, nil, nil, nil},
+	)
+
+	migs, err := store.GetAllByName("Migration_1_up")
+	if err != nil {
+		t.Fatalf(`unable to get migrations -- %v`, err)
+	}
+
+	if len(migs) != 2 {
+		t.Fatalf(`expected 2 migrations got %v`, len(migs))
+	}
+
+	if migs[0].Error != "some failure" || migs[1].Status != fofm.STATUS_SUCCESS {
+		t.Errorf(`unexpected migrations -- %+v`, migs)
+	}
+}
+
+func TestPostgresLockUsesAdvisoryLock(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgres(db)
+	fd.setRows([]string{"locked"}, []driver.Value{true})
+
+	err := store.Lock(context.Background(), 0)
+	if err != nil {
+		t.Fatalf(`unable to lock -- %v`, err)
+	}
+
+	if !strings.Contains(fd.lastQuery().query, `pg_try_advisory_lock($1)`) {
+		t.Errorf(`expected an advisory lock -- %v`, fd.lastQuery().query)
+	}
+
+	err = store.Unlock()
+	if err != nil {
+		t.Fatalf(`unable to unlock -- %v`, err)
+	}
+
+	if !strings.Contains(fd.lastQuery().query, `pg_advisory_unlock($1)`) {
+		t.Errorf(`expected the advisory lock to be released -- %v`, fd.lastQuery().query)
+	}
+}
+
+func TestPostgresLockWaitsForHeldLock(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewPostgres(db)
+	fd.setRows([]string{"locked"}, []driver.Value{false})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	fd.setRowsFor("pg_locks", []string{"pid", "application_name", "client_addr", "connected"}, []driver.Value{int64(42), "api", "10.0.0.1", float64(90)})
+
+	err := store.Lock(ctx, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(`expected the lock to time out got %v`, err)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), `pid 42 (application "api", client "10.0.0.1", connected 1m30s ago)`) {
+		t.Errorf(`expected the error to name the holder got %v`, err)
+	}
+}
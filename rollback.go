@@ -0,0 +1,97 @@
+package fofm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNothingToRollback is returned by Redo when no up migration is applied
+var ErrNothingToRollback = errors.New("there are no applied migrations to roll back")
+
+// Rollback will run the down migrations of the last n successfully applied up
+// migrations, most recently applied first. What is applied is based on the
+// Store history, not the migration names. When fewer than n migrations are
+// applied, all of them are rolled back
+func (m *FOFM) Rollback(n int) error {
+	return m.RollbackContext(context.Background(), n)
+}
+
+// RollbackContext is Rollback with a context
+func (m *FOFM) RollbackContext(ctx context.Context, n int) error {
+	return m.operate(ctx, OPERATION_ROLLBACK, func() (Plan, error) {
+		return m.planRollback(n)
+	})
+}
+
+// Redo will roll back the most recently applied up migration and then apply
+// it again
+func (m *FOFM) Redo() error {
+	return m.RedoContext(context.Background())
+}
+
+// RedoContext is Redo with a context
+func (m *FOFM) RedoContext(ctx context.Context) error {
+	return m.operate(ctx, OPERATION_REDO, m.planRedo)
+}
+
+// PlanRollback returns the migrations that Rollback would run without running
+// them
+func (m *FOFM) PlanRollback(n int) (Plan, error) {
+	return m.planRollback(n)
+}
+
+// PlanRedo returns the migrations that Redo would run without running them
+func (m *FOFM) PlanRedo() (Plan, error) {
+	return m.planRedo()
+}
+
+func (m *FOFM) planRollback(n int) (Plan, error) {
+	plan := Plan{}
+	if n < 1 {
+		return plan, fmt.Errorf(`the number of migrations to roll back must be at least 1, got %v`, n)
+	}
+
+	state, err := m.AppliedState()
+	if err != nil {
+		return plan, err
+	}
+
+	applied := state.Applied()
+	for i := len(applied) - 1; i >= 0 && len(plan.Steps) < n; i-- {
+		mig := m.DownMigrations.ByTimestamp(applied[i].Migration.Timestamp)
+		if mig == nil {
+			return Plan{}, fmt.Errorf(`%v cannot be rolled back, it does not have a down migration`, applied[i].Migration.Name)
+		}
+
+		plan.Steps = append(plan.Steps, PlanStep{
+			Migration: *mig,
+			Reason:    REASON_ROLLBACK,
+		})
+	}
+
+	return plan, nil
+}
+
+func (m *FOFM) planRedo() (Plan, error) {
+	plan, err := m.planRollback(1)
+	if err != nil {
+		return plan, err
+	}
+
+	if len(plan.Steps) == 0 {
+		return plan, ErrNothingToRollback
+	}
+
+	mig := m.UpMigrations.ByTimestamp(plan.Steps[0].Migration.Timestamp)
+	if mig == nil {
+		return Plan{}, fmt.Errorf(`%v cannot be redone, its up migration is not defined`, plan.Steps[0].Migration.Name)
+	}
+
+	plan.Steps = append(plan.Steps, PlanStep{
+		Migration: *mig,
+		Reason:    REASON_REDO,
+	})
+
+	return plan, nil
+}
@@ -1,8 +1,14 @@
 package fofm
 
 import (
+	"context"
+	"database/sql"
 	"io/fs"
 	"io/ioutil"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"time"
 )
 
 type Setting func(ins *FOFM) error
@@ -11,6 +17,17 @@ type WriteFile func(filename string, data []byte, perm fs.FileMode) error
 var DefaultSettings = []Setting{
 	// set the default writer
 	FileWriter,
+
+	// wait up to a minute for other runners to finish
+	WithLockTimeout(time.Minute),
+
+	// refuse to run migrations that were merged after later ones were
+	// applied until the app chooses how to treat them
+	OutOfOrderError,
+
+	// record where and what version of the app ran each migration
+	OSHostname,
+	BuildVersion,
 }
 
 // FileWriter sets the writer to be the deafult file writer
@@ -21,3 +38,222 @@ func FileWriter(ins *FOFM) error {
 
 	return nil
 }
+
+// WithSQLDB sets the database that transactional migrations, those that
+// accept a *sql.Tx, are run against
+func WithSQLDB(db *sql.DB) Setting {
+	return func(ins *FOFM) error {
+		ins.SQLDB = db
+
+		return nil
+	}
+}
+
+// SQLBackslashEscapes makes RegisterSQL split files with SplitMySQLStatements,
+// where a backslash escapes a quote. It is the default for a MySQL Store and
+// is needed when the Store is not a MySQL store but WithSQLDB is a MySQL or
+// MariaDB database
+func SQLBackslashEscapes(ins *FOFM) error {
+	ins.SQLBackslashEscapes = true
+
+	return nil
+}
+
+// WithChecksums sets the checksums of the migration methods, keyed by the
+// method name, for binaries that are deployed without their source. They are
+// usually generated with MigrationChecksums at build time and embedded. The
+// source isn't read when they are set
+func WithChecksums(checksums map[string]string) Setting {
+	return func(ins *FOFM) error {
+		for name, checksum := range checksums {
+			ins.checksums[name] = checksum
+		}
+
+		return nil
+	}
+}
+
+// WithLockTimeout sets how long Latest, Up and Down will wait for the
+// migration lock when the Store is a Locker. 0 waits until the context is done
+func WithLockTimeout(timeout time.Duration) Setting {
+	return func(ins *FOFM) error {
+		ins.LockTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithStaleLockAfter sets when a held migration lock is considered abandoned
+// by a crashed runner and can be taken over. It should be longer than your
+// longest migration. 0, the default, never takes over a lock and
+// ErrLockNotAcquired reports the lock's holder and age instead
+func WithStaleLockAfter(staleAfter time.Duration) Setting {
+	return func(ins *FOFM) error {
+		ins.StaleLockAfter = staleAfter
+
+		return nil
+	}
+}
+
+// OutOfOrderError makes Latest, Up and Goto refuse to run when a migration that is
+// not applied is older than one that is. The error lists those migrations.
+// This is the default
+func OutOfOrderError(ins *FOFM) error {
+	ins.OutOfOrder = OUT_OF_ORDER_ERROR
+
+	return nil
+}
+
+// OutOfOrderAllow makes Latest, Up and Goto run migrations that are older than one
+// that is already applied
+func OutOfOrderAllow(ins *FOFM) error {
+	ins.OutOfOrder = OUT_OF_ORDER_ALLOW
+
+	return nil
+}
+
+// OutOfOrderWarn is OutOfOrderAllow that also emits a WARNING_OUT_OF_ORDER
+// warning before the migrations are run
+func OutOfOrderWarn(ins *FOFM) error {
+	ins.OutOfOrder = OUT_OF_ORDER_WARN
+
+	return nil
+}
+
+// WithWarningHandler sets the func that receives warnings, for example about
+// out of order migrations. Warnings are also written to the Logger. There is
+// no handler by default
+func WithWarningHandler(handler func(Warning)) Setting {
+	return func(ins *FOFM) error {
+		ins.Warn = handler
+
+		return nil
+	}
+}
+
+// WithLogger sets the logger that discovery, plan selection, each migration's
+// start and finish, Store writes and errors that cannot be returned are
+// written to. The attribute keys are the LOG_KEY_ constants. Nothing is
+// logged by default
+func WithLogger(logger *slog.Logger) Setting {
+	return func(ins *FOFM) error {
+		ins.Logger = logger
+
+		return nil
+	}
+}
+
+// OSHostname sets the Host recorded with every run to the host name reported
+// by the operating system, which is the pod name in Kubernetes. This is the
+// default
+func OSHostname(ins *FOFM) error {
+	host, err := os.Hostname()
+	if err == nil {
+		ins.Host = host
+	}
+
+	return nil
+}
+
+// WithHost sets the Host recorded with every run
+func WithHost(host string) Setting {
+	return func(ins *FOFM) error {
+		ins.Host = host
+
+		return nil
+	}
+}
+
+// BuildVersion sets the Version recorded with every run to the version of
+// the main module or, when it was built from a checkout, the VCS revision
+// that the binary was built from. This is the default
+func BuildVersion(ins *FOFM) error {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		ins.Version = info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && ins.Version == "" {
+			ins.Version = setting.Value
+		}
+	}
+
+	return nil
+}
+
+// WithVersion sets the Version, for example the app's version or git SHA,
+// recorded with every run
+func WithVersion(version string) Setting {
+	return func(ins *FOFM) error {
+		ins.Version = version
+
+		return nil
+	}
+}
+
+// WithOperator sets the Operator, the person or system running the
+// migrations, recorded with every run. It is empty by default
+func WithOperator(operator string) Setting {
+	return func(ins *FOFM) error {
+		ins.Operator = operator
+
+		return nil
+	}
+}
+
+// WithTracer sets the Tracer that every operation and migration is run in a
+// span of. Migrations that accept a context receive their span's context
+func WithTracer(tracer Tracer) Setting {
+	return func(ins *FOFM) error {
+		ins.Tracer = tracer
+
+		return nil
+	}
+}
+
+// WithBeforeAll adds a hook that is called before a batch of migrations, for
+// example everything that Latest will run, is started. It is not called when
+// there is nothing to run. An error stops the batch from starting
+func WithBeforeAll(hook func(ctx context.Context) error) Setting {
+	return func(ins *FOFM) error {
+		ins.beforeAll = append(ins.beforeAll, hook)
+
+		return nil
+	}
+}
+
+// WithAfterAll adds a hook that is called with a summary of the batch once it
+// has finished, whether it succeeded or not
+func WithAfterAll(hook func(ctx context.Context, summary RunSummary)) Setting {
+	return func(ins *FOFM) error {
+		ins.afterAll = append(ins.afterAll, hook)
+
+		return nil
+	}
+}
+
+// WithBeforeEach adds a hook that is called before each migration is run. An
+// error stops the migration from running, is recorded as its failure and
+// aborts the batch
+func WithBeforeEach(hook func(ctx context.Context, mig Migration) error) Setting {
+	return func(ins *FOFM) error {
+		ins.beforeEach = append(ins.beforeEach, hook)
+
+		return nil
+	}
+}
+
+// WithAfterEach adds a hook that is called with each recorded run and its
+// error, if there was one
+func WithAfterEach(hook func(ctx context.Context, mig Migration, err error)) Setting {
+	return func(ins *FOFM) error {
+		ins.afterEach = append(ins.afterEach, hook)
+
+		return nil
+	}
+}
@@ -0,0 +1,367 @@
+package fofm
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQL_NO_TRANSACTION is the marker that, when it is on its own line in a SQL
+// file, runs the file's statements without a wrapping transaction. It is
+// needed for statements like CREATE INDEX CONCURRENTLY
+const SQL_NO_TRANSACTION = "-- fofm:no-transaction"
+
+var sqlFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// sqlFile is a parsed NNNN_description.direction.sql file
+type sqlFile struct {
+	_           struct{}
+	id          int64
+	description string
+	direction   string
+	statements  []string
+	noTx        bool
+	checksum    string
+}
+
+// RegisterSQL loads NNNN_description.up.sql and NNNN_description.down.sql
+// files from the root of fsys, typically an embed.FS, and registers them as
+// migrations. The statements are run against the database passed to the
+// WithSQLDB setting, inside of a single transaction unless the file contains
+// the SQL_NO_TRANSACTION marker. Files are split into statements with
+// SplitStatements or, when backslashes escape quotes in the database, with
+// SplitMySQLStatements. See the SQLBackslashEscapes setting
+func (m *FOFM) RegisterSQL(fsys fs.FS) error {
+	if m.SQLDB == nil {
+		return fmt.Errorf(`sql migrations require a *sql.DB. use the WithSQLDB setting`)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf(`unable to read sql migrations -- %w`, err)
+	}
+
+	files := map[int64]map[string]sqlFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !sqlFileName.MatchString(entry.Name()) {
+			continue
+		}
+
+		file, err := readSQLFile(fsys, entry.Name(), m.backslashEscapes())
+		if err != nil {
+			return err
+		}
+
+		if _, ok := files[file.id]; !ok {
+			files[file.id] = map[string]sqlFile{}
+		}
+
+		if _, ok := files[file.id][file.direction]; ok {
+			return fmt.Errorf(`there is more than one %v sql migration for %v`, file.direction, file.id)
+		}
+
+		files[file.id][file.direction] = file
+	}
+
+	ids := []int64{}
+	for id := range files {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		pair := files[id]
+		if _, ok := pair[up]; !ok {
+			return fmt.Errorf(`sql migration %v has a down file but no up file`, id)
+		}
+
+		funcs := map[string]migrationFunc{}
+		checksums := map[string]string{}
+		for direction, file := range pair {
+			funcs[direction] = m.sqlMigrationFunc(file)
+			checksums[direction] = file.checksum
+		}
+
+		err = m.register(SOURCE_SQL, id, pair[up].description, funcs, checksums)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backslashEscapes reports whether the SQL files are split with
+// SplitMySQLStatements. It is when the SQLBackslashEscapes setting was used
+// or the Store is a MySQL store
+func (m *FOFM) backslashEscapes() bool {
+	_, mysql := m.DB.(*MySQL)
+
+	return m.SQLBackslashEscapes || mysql
+}
+
+// sqlMigrationFunc runs the file's statements in order. Without a
+// transaction they are still run on a single connection so that session
+// state, like SET or temporary tables, carries over between them
+func (m *FOFM) sqlMigrationFunc(file sqlFile) migrationFunc {
+	if file.noTx {
+		return migrationFunc{
+			call: func(ctx context.Context) error {
+				conn, err := m.SQLDB.Conn(ctx)
+				if err != nil {
+					return err
+				}
+
+				defer conn.Close()
+
+				for i, statement := range file.statements {
+					_, err := conn.ExecContext(ctx, statement)
+					if err != nil {
+						return fmt.Errorf(`statement %d -- %w`, i+1, err)
+					}
+				}
+
+				return nil
+			},
+		}
+	}
+
+	return migrationFunc{
+		tx: func(ctx context.Context, tx *sql.Tx) error {
+			for i, statement := range file.statements {
+				_, err := tx.ExecContext(ctx, statement)
+				if err != nil {
+					return fmt.Errorf(`statement %d -- %w`, i+1, err)
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// readSQLFile parses the file. backslashEscapes splits it with
+// SplitMySQLStatements
+func readSQLFile(fsys fs.FS, name string, backslashEscapes bool) (sqlFile, error) {
+	parts := sqlFileName.FindStringSubmatch(name)
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return sqlFile{}, fmt.Errorf(`incorrect sql migration name: %v -- %w`, name, err)
+	}
+
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return sqlFile{}, fmt.Errorf(`unable to read sql migration %v -- %w`, name, err)
+	}
+
+	file := sqlFile{
+		id:          id,
+		description: parts[2],
+		direction:   parts[3],
+	}
+
+	file.statements = SplitStatements(string(src))
+	if backslashEscapes {
+		file.statements = SplitMySQLStatements(string(src))
+	}
+
+	for _, line := range strings.Split(string(src), "\n") {
+		if strings.TrimSpace(line) == SQL_NO_TRANSACTION {
+			file.noTx = true
+			break
+		}
+	}
+
+	sum := sha256.Sum256(src)
+	file.checksum = hex.EncodeToString(sum[:])
+
+	return file, nil
+}
+
+var dollarQuoteTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// SplitStatements splits src on the semicolons that end its statements.
+// Semicolons inside of quotes, identifiers, comments, Postgres dollar quoted
+// bodies and the BEGIN ... END body of a CREATE TRIGGER, PROCEDURE, FUNCTION
+// or EVENT are ignored. A backslash only escapes a quote in Postgres E”
+// strings, use SplitMySQLStatements for MySQL. Statements that only contain
+// comments are dropped
+func SplitStatements(src string) []string {
+	return splitStatements(src, false)
+}
+
+// SplitMySQLStatements is SplitStatements where a backslash escapes the next
+// character in every quoted string, which is MySQL's default
+func SplitMySQLStatements(src string) []string {
+	return splitStatements(src, true)
+}
+
+// blockWords are the words that can start a create statement whose body is a
+// BEGIN ... END block
+var blockWords = map[string]bool{
+	"TRIGGER":   true,
+	"PROCEDURE": true,
+	"FUNCTION":  true,
+	"EVENT":     true,
+}
+
+// endsWithoutBlock are the words that follow an END that closes a MySQL
+// compound statement, which is not counted as a block
+var endsWithoutBlock = map[string]bool{
+	"IF":     true,
+	"LOOP":   true,
+	"WHILE":  true,
+	"REPEAT": true,
+}
+
+func splitStatements(src string, backslashEscapes bool) []string {
+	statements := []string{}
+	var current strings.Builder
+	var hasCode, create, routine bool
+	var words, depth int
+
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		if hasCode && statement != "" {
+			statements = append(statements, statement)
+		}
+
+		current.Reset()
+		hasCode, create, routine = false, false, false
+		words, depth = 0, 0
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		var end int
+
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			end = strings.Index(rest, "\n")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			current.WriteString(rest[:end])
+			i += end
+			continue
+		case strings.HasPrefix(rest, "/*"):
+			end = strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+
+			current.WriteString(rest[:end])
+			i += end
+			continue
+		case rest[0] == '\'' || rest[0] == '"':
+			end = quotedEnd(rest, rest[0], backslashEscapes)
+		case rest[0] == '`':
+			end = quotedEnd(rest, rest[0], false)
+		case (rest[0] == 'E' || rest[0] == 'e') && len(rest) > 1 && rest[1] == '\'' && !isWordByte(src, i-1):
+			end = 1 + quotedEnd(rest[1:], '\'', true)
+		case rest[0] == '$' && dollarQuoteTag.MatchString(rest):
+			tag := dollarQuoteTag.FindString(rest)
+			end = strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 2 * len(tag)
+			}
+		case rest[0] == ';' && depth == 0:
+			flush()
+			i++
+			continue
+		case isWordByte(src, i) && !isWordByte(src, i-1):
+			end = wordEnd(rest)
+			word := strings.ToUpper(rest[:end])
+			words++
+
+			switch {
+			case words == 1:
+				create = word == "CREATE"
+			case create && depth == 0 && blockWords[word]:
+				routine = true
+			case routine && (word == "BEGIN" || (word == "CASE" && depth > 0)):
+				depth++
+			case depth > 0 && word == "END":
+				next := strings.TrimLeft(rest[end:], " \t\r\n")
+				if !endsWithoutBlock[strings.ToUpper(next[:wordEnd(next)])] {
+					depth--
+				}
+			}
+		default:
+			end = 1
+		}
+
+		if strings.TrimSpace(rest[:end]) != "" {
+			hasCode = true
+		}
+
+		current.WriteString(rest[:end])
+		i += end
+	}
+
+	flush()
+
+	return statements
+}
+
+// quotedEnd returns the length of the quoted string at the start of src. A
+// doubled quote is an escaped quote and, when backslashEscapes is true, so
+// is any character following a backslash
+func quotedEnd(src string, quote byte, backslashEscapes bool) int {
+	for i := 1; i < len(src); i++ {
+		if backslashEscapes && src[i] == '\\' {
+			i++
+			continue
+		}
+
+		if src[i] != quote {
+			continue
+		}
+
+		if i+1 < len(src) && src[i+1] == quote {
+			i++
+			continue
+		}
+
+		return i + 1
+	}
+
+	return len(src)
+}
+
+// isWordByte reports whether the byte at i in src is part of a word. It is
+// false when i is out of range
+func isWordByte(src string, i int) bool {
+	if i < 0 || i >= len(src) {
+		return false
+	}
+
+	c := src[i]
+
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// wordEnd returns the length of the word at the start of src
+func wordEnd(src string) int {
+	end := 0
+	for isWordByte(src, end) {
+		end++
+	}
+
+	return end
+}
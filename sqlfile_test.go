@@ -0,0 +1,242 @@
+package fofm_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/emehrkay/fofm"
+)
+
+func TestSplitStatements(t *testing.T) {
+	src := `-- create the table
+CREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b');
+/* a ; comment */
+INSERT INTO "odd;name" VALUES (1, 'it''s; fine');
+CREATE FUNCTION noop() RETURNS void AS $body$ BEGIN; END; $body$ LANGUAGE plpgsql;
+SELECT $1;
+-- trailing comment only
+`
+
+	statements := fofm.SplitStatements(src)
+	expected := []string{
+		"-- create the table\nCREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b')",
+		`/* a ; comment */` + "\n" + `INSERT INTO "odd;name" VALUES (1, 'it''s; fine')`,
+		`CREATE FUNCTION noop() RETURNS void AS $body$ BEGIN; END; $body$ LANGUAGE plpgsql`,
+		`SELECT $1`,
+	}
+
+	if len(statements) != len(expected) {
+		t.Fatalf(`expected %v statements got %v -- %q`, len(expected), len(statements), statements)
+	}
+
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf(`expected statement %d to be %q got %q`, i, expected[i], statements[i])
+		}
+	}
+}
+
+func TestSplitStatementsEscapesAndBlocks(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		split    func(string) []string
+		src      string
+		expected []string
+	}{
+		{
+			"postgres escape string",
+			fofm.SplitStatements,
+			`SELECT E'it\'s;'; SELECT 3`,
+			[]string{`SELECT E'it\'s;'`, `SELECT 3`},
+		},
+		{
+			"standard strings do not use backslashes",
+			fofm.SplitStatements,
+			`SELECT 'C:\'; SELECT 3`,
+			[]string{`SELECT 'C:\'`, `SELECT 3`},
+		},
+		{
+			"mysql backslash escapes",
+			fofm.SplitMySQLStatements,
+			`INSERT INTO a VALUES ('it\'s; fine', "say \"hi;\""); SELECT 3`,
+			[]string{`INSERT INTO a VALUES ('it\'s; fine', "say \"hi;\"")`, `SELECT 3`},
+		},
+		{
+			"sqlite trigger",
+			fofm.SplitStatements,
+			`CREATE TRIGGER x AFTER INSERT ON a BEGIN UPDATE a SET b=1; UPDATE a SET c=CASE WHEN b THEN 1 ELSE 2 END; END; SELECT 3;`,
+			[]string{`CREATE TRIGGER x AFTER INSERT ON a BEGIN UPDATE a SET b=1; UPDATE a SET c=CASE WHEN b THEN 1 ELSE 2 END; END`, `SELECT 3`},
+		},
+		{
+			"mysql procedure",
+			fofm.SplitMySQLStatements,
+			"CREATE PROCEDURE p() BEGIN IF 1 THEN SELECT 1; END IF; BEGIN SELECT 2; END; END;\nSELECT 3;",
+			[]string{`CREATE PROCEDURE p() BEGIN IF 1 THEN SELECT 1; END IF; BEGIN SELECT 2; END; END`, `SELECT 3`},
+		},
+		{
+			"transaction blocks are not bodies",
+			fofm.SplitStatements,
+			`BEGIN; INSERT INTO a VALUES (1); END;`,
+			[]string{`BEGIN`, `INSERT INTO a VALUES (1)`, `END`},
+		},
+	} {
+		statements := test.split(test.src)
+		if strings.Join(statements, "|") != strings.Join(test.expected, "|") {
+			t.Errorf(`%v: expected %q got %q`, test.name, test.expected, statements)
+		}
+	}
+}
+
+func getSQLFileManager(t *testing.T) (*fofm.FOFM, *fofm.SQLite) {
+	db, err := fofm.NewSQLite(filepath.Join(t.TempDir(), "fofm.db"))
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	mig, err := fofm.New(db, TestMigrationManagerMultiple{}, fofm.WithSQLDB(db.SQLDB()))
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	return mig, db
+}
+
+func TestRegisterSQL(t *testing.T) {
+	mig, db := getSQLFileManager(t)
+	files := fstest.MapFS{
+		"0012_add_users.up.sql": {Data: []byte(`
+CREATE TABLE users (id INTEGER, name TEXT);
+INSERT INTO users VALUES (1, 'one;two');
+`)},
+		"0012_add_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+		"0016_index_users.up.sql": {Data: []byte(`-- fofm:no-transaction
+CREATE INDEX users_name ON users (name);
+`)},
+		"0016_index_users.down.sql": {Data: []byte(`DROP INDEX users_name;`)},
+		"README.md":                 {Data: []byte(`not a migration`)},
+	}
+
+	err := mig.RegisterSQL(files)
+	if err != nil {
+		t.Fatalf(`unable to register sql migrations -- %v`, err)
+	}
+
+	expected := []string{"Migration_1_up", "Migration_5_up", "Migration_10_up", "Migration_12_add_users_up", "Migration_15_up", "Migration_16_index_users_up", "Migration_18_up"}
+	if strings.Join(mig.UpMigrations.Names(), ",") != strings.Join(expected, ",") {
+		t.Errorf(`expected the up migrations %v got %v`, expected, mig.UpMigrations.Names())
+	}
+
+	err = mig.Latest()
+	if err != nil {
+		t.Fatalf("unable to run latest -- %v", err)
+	}
+
+	var name string
+	err = db.SQLDB().QueryRow(`SELECT name FROM users WHERE id = 1`).Scan(&name)
+	if err != nil || name != "one;two" {
+		t.Errorf(`expected the inserted user got %v -- %v`, name, err)
+	}
+
+	last, err := mig.DB.LastRunByName("Migration_12_add_users_up")
+	if err != nil {
+		t.Fatalf(`unable to get the last run -- %v`, err)
+	}
+
+	if last.Checksum == "" {
+		t.Errorf(`expected the checksum of the sql file to be recorded`)
+	}
+
+	err = mig.Down("Migration_12_add_users_down")
+	if err != nil {
+		t.Fatalf("unable to run down -- %v", err)
+	}
+
+	err = db.SQLDB().QueryRow(`SELECT name FROM users WHERE id = 1`).Scan(&name)
+	if err == nil {
+		t.Errorf(`expected users to be dropped`)
+	}
+}
+
+func TestFailedSQLMigrationIsRolledBack(t *testing.T) {
+	mig, db := getSQLFileManager(t)
+	files := fstest.MapFS{
+		"0012_add_users.up.sql": {Data: []byte(`
+CREATE TABLE users (id INTEGER);
+INSERT INTO missing VALUES (1);
+`)},
+	}
+
+	err := mig.RegisterSQL(files)
+	if err != nil {
+		t.Fatalf(`unable to register sql migrations -- %v`, err)
+	}
+
+	err = mig.Up("Migration_12_add_users_up")
+	if err == nil || !strings.Contains(err.Error(), "statement 2") {
+		t.Errorf(`expected the second statement to fail got %v`, err)
+	}
+
+	var count int
+	err = db.SQLDB().QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+	if err == nil {
+		t.Errorf(`expected the create table to be rolled back`)
+	}
+}
+
+func TestRegisterSQLErrors(t *testing.T) {
+	noSQLDB, err := fofm.New(getDB(t), TestMigrationManager{})
+	if err != nil {
+		t.Fatalf("expected New but got -- %s", err)
+	}
+
+	err = noSQLDB.RegisterSQL(fstest.MapFS{})
+	if err == nil {
+		t.Errorf(`expected an error without WithSQLDB`)
+	}
+
+	for name, files := range map[string]fstest.MapFS{
+		"down without up": {
+			"0002_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+		},
+		"duplicate id": {
+			"0002_users.up.sql":  {Data: []byte(`SELECT 1;`)},
+			"0002_emails.up.sql": {Data: []byte(`SELECT 1;`)},
+		},
+		"already a method": {
+			"0001_users.up.sql": {Data: []byte(`SELECT 1;`)},
+		},
+	} {
+		mig, _ := getSQLFileManager(t)
+		err = mig.RegisterSQL(files)
+		if err == nil {
+			t.Errorf(`expected an error for %v`, name)
+		}
+	}
+}
+
+func TestSQLMigrationWithoutTransactionKeepsSession(t *testing.T) {
+	mig, db := getSQLFileManager(t)
+
+	// every statement would get a new connection from the pool
+	db.SQLDB().SetMaxIdleConns(0)
+
+	files := fstest.MapFS{
+		"0012_copy_users.up.sql": {Data: []byte(`-- fofm:no-transaction
+CREATE TEMP TABLE staged (id INTEGER);
+INSERT INTO staged VALUES (1);
+CREATE TABLE users AS SELECT id FROM staged;
+`)},
+	}
+
+	err := mig.RegisterSQL(files)
+	if err != nil {
+		t.Fatalf(`unable to register sql migrations -- %v`, err)
+	}
+
+	err = mig.Up("Migration_12_copy_users_up")
+	if err != nil {
+		t.Fatalf(`expected the statements to share a session -- %v`, err)
+	}
+}
@@ -0,0 +1,519 @@
+package fofm
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Dialect describes the differences between the databases that SQLStore can
+// keep its records in. Adding support for a new database/sql database only
+// requires a new Dialect
+type Dialect interface {
+	// Placeholder returns the bind parameter for the nth (starting at 1)
+	// argument of a query
+	Placeholder(n int) string
+
+	// QuoteIdentifier quotes a schema, table or column name
+	QuoteIdentifier(name string) string
+
+	// CreateStatements returns the "create if doesnt exist" statements needed
+	// to create the table. Both schema and table are already quoted, schema is
+	// empty when one was not provided and table is schema qualified when it was.
+	// Columns missing from the statements, or from tables created by earlier
+	// versions, are added by CreateStore using upgradeColumns
+	CreateStatements(schema, table string) []string
+
+	// EncodeTime converts a time into the value stored in a timestamp column
+	EncodeTime(t time.Time) any
+
+	// DecodeTime converts a scanned timestamp column into a time
+	DecodeTime(src any) (time.Time, error)
+}
+
+// NewSQLStoreWithSchema creates a SQLStore that keeps its records in
+// schema.tablename
+func NewSQLStoreWithSchema(db *sql.DB, dialect Dialect, schema, tablename string) *SQLStore {
+	return &SQLStore{
+		db:        db,
+		dialect:   dialect,
+		schema:    schema,
+		tablename: tablename,
+	}
+}
+
+// NewSQLStore creates a SQLStore that keeps its records in tablename
+func NewSQLStore(db *sql.DB, dialect Dialect, tablename string) *SQLStore {
+	return NewSQLStoreWithSchema(db, dialect, "", tablename)
+}
+
+// SQLStore is a Store for any database/sql database. The differences between
+// databases are handled by its Dialect
+type SQLStore struct {
+	_         struct{}
+	db        *sql.DB
+	dialect   Dialect
+	schema    string
+	tablename string
+	lockOwner string
+
+	// sessionLock is set by the stores that replace the lock row with a
+	// database lock, so the lock table isn't created
+	sessionLock bool
+}
+
+// table returns the quoted, and schema qualified when there is one, table name
+func (s *SQLStore) table() string {
+	return s.qualify(s.tablename)
+}
+
+// lockTable returns the quoted name of the table that holds the lock row
+func (s *SQLStore) lockTable() string {
+	return s.qualify(s.tablename + "_lock")
+}
+
+func (s *SQLStore) qualify(name string) string {
+	table := s.dialect.QuoteIdentifier(name)
+	if s.schema == "" {
+		return table
+	}
+
+	return s.dialect.QuoteIdentifier(s.schema) + "." + table
+}
+
+func (s *SQLStore) Dialect() Dialect {
+	return s.dialect
+}
+
+func (s *SQLStore) SQLDB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLStore) Connect() error {
+	return s.db.Ping()
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) CreateStore() error {
+	var schema string
+	if s.schema != "" {
+		schema = s.dialect.QuoteIdentifier(s.schema)
+	}
+
+	for _, query := range s.dialect.CreateStatements(schema, s.table()) {
+		_, err := s.db.Exec(query)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.upgrade()
+	if err != nil || s.sessionLock {
+		return err
+	}
+
+	// the lock table only uses portable types so it doesnt need the dialect
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER NOT NULL PRIMARY KEY,
+		owner VARCHAR(64) NOT NULL,
+		acquired BIGINT NOT NULL
+	)`, s.lockTable())
+	_, err = s.db.Exec(query)
+
+	return err
+}
+
+// upgradeColumns are the columns that were added to the table after its
+// first version. Their definitions only use portable types
+var upgradeColumns = []struct {
+	name       string
+	definition string
+}{
+	{"checksum", "TEXT NULL"},
+	{"duration", "BIGINT NULL"},
+	{"host", "TEXT NULL"},
+	{"pid", "BIGINT NULL"},
+	{"version", "TEXT NULL"},
+	{"operator", "TEXT NULL"},
+}
+
+// upgrade adds any upgradeColumns that are missing from an existing table
+func (s *SQLStore) upgrade() error {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE 1 = 0`, s.table())
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, column := range columns {
+		existing[strings.ToLower(column)] = true
+	}
+
+	for _, column := range upgradeColumns {
+		if existing[column.name] {
+			continue
+		}
+
+		query := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, s.table(), column.name, column.definition)
+		_, err = s.db.Exec(query)
+		if err != nil {
+			return fmt.Errorf(`unable to add the %v column -- %w`, column.name, err)
+		}
+	}
+
+	return nil
+}
+
+const sqlLockRetry = 100 * time.Millisecond
+
+// Lock holds the migration lock by inserting the single row into the lock
+// table. Since the error for a duplicate key differs per driver, a failed
+// insert is only retried when the lock row exists. Any other error is returned
+func (s *SQLStore) Lock(ctx context.Context, staleAfter time.Duration) error {
+	owner, err := newLockOwner()
+	if err != nil {
+		return err
+	}
+
+	insert := fmt.Sprintf(`
+	INSERT INTO
+		%s (id, owner, acquired)
+	VALUES
+		(1, %s, %s)`, s.lockTable(), s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+	stale := fmt.Sprintf(`
+	DELETE FROM
+		%s
+	WHERE
+		id = 1 AND acquired < %s`, s.lockTable(), s.dialect.Placeholder(1))
+
+	released := false
+	var held sqlLock
+	for {
+		if staleAfter > 0 {
+			_, err = s.db.ExecContext(ctx, stale, time.Now().Add(-staleAfter).UnixNano())
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = s.db.ExecContext(ctx, insert, owner, time.Now().UnixNano())
+		if err == nil {
+			s.lockOwner = owner
+			return nil
+		}
+
+		var heldErr error
+		held, heldErr = s.heldLock(ctx)
+		switch {
+		case errors.Is(heldErr, sql.ErrNoRows) && !released:
+			// the holder may have released the lock after the insert failed
+			released = true
+			continue
+		case heldErr != nil:
+			return fmt.Errorf(`unable to insert the lock row -- %w`, err)
+		}
+
+		released = false
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf(`the lock is held by %v, acquired %v ago -- %w`, held.owner, time.Since(held.acquired).Round(time.Second), ctx.Err())
+		case <-time.After(sqlLockRetry):
+		}
+	}
+}
+
+// sqlLock is the row in the lock table
+type sqlLock struct {
+	_        struct{}
+	owner    string
+	acquired time.Time
+}
+
+// heldLock returns the lock row. It returns sql.ErrNoRows when the lock
+// isn't held
+func (s *SQLStore) heldLock(ctx context.Context) (sqlLock, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		owner, acquired
+	FROM
+		%s
+	WHERE
+		id = 1`, s.lockTable())
+
+	var held sqlLock
+	var acquired int64
+	err := s.db.QueryRowContext(ctx, query).Scan(&held.owner, &acquired)
+	if err != nil {
+		return sqlLock{}, err
+	}
+
+	held.acquired = time.Unix(0, acquired)
+
+	return held, nil
+}
+
+func (s *SQLStore) Unlock() error {
+	query := fmt.Sprintf(`
+	DELETE FROM
+		%s
+	WHERE
+		id = 1 AND owner = %s`, s.lockTable(), s.dialect.Placeholder(1))
+	_, err := s.db.Exec(query, s.lockOwner)
+
+	return err
+}
+
+func (s *SQLStore) ClearStore() error {
+	query := fmt.Sprintf(`
+	DELETE FROM %s`, s.table())
+	_, err := s.db.Exec(query)
+
+	return err
+}
+
+func (s *SQLStore) LastRun() (*Migration, error) {
+	return s.queryMigration(s.selectQuery("", "DESC", true))
+}
+
+func (s *SQLStore) LastStatusRun(status string) (*Migration, error) {
+	return s.queryMigration(s.selectQuery("status", "DESC", true), status)
+}
+
+func (s *SQLStore) LastRunByName(name string) (*Migration, error) {
+	return s.queryMigration(s.selectQuery("name", "DESC", true), name)
+}
+
+func (s *SQLStore) List() (MigrationSet, error) {
+	return s.queryMigrations(s.selectQuery("", "ASC", false))
+}
+
+func (s *SQLStore) GetAllByName(name string) (MigrationSet, error) {
+	return s.queryMigrations(s.selectQuery("name", "ASC", false), name)
+}
+
+func (s *SQLStore) Save(current Migration, err error) error {
+	return s.save(s.db, current, err)
+}
+
+func (s *SQLStore) SaveTx(tx *sql.Tx, current Migration, err error) error {
+	return s.save(tx, current, err)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (s *SQLStore) save(db execer, current Migration, err error) error {
+	placeholders := []string{}
+	for i := 1; i <= 12; i++ {
+		placeholders = append(placeholders, s.dialect.Placeholder(i))
+	}
+
+	query := fmt.Sprintf(`
+	INSERT INTO
+		%s (name, direction, status, error, timestamp, created, checksum, duration, host, pid, version, operator)
+	VALUES
+		(%s)`, s.table(), strings.Join(placeholders, ", "))
+
+	var errText string
+	if err != nil {
+		errText = err.Error()
+	}
+
+	timestamp := s.dialect.EncodeTime(current.Timestamp)
+	now := s.dialect.EncodeTime(time.Now())
+	_, err = db.Exec(query, current.Name, current.Direction, current.Status, errText, timestamp, now, current.Checksum,
+		int64(current.Duration), current.Host, current.PID, current.Version, current.Operator)
+
+	return err
+}
+
+// selectQuery builds a select ordered by id. When column is provided the rows
+// are filtered by it using the first placeholder
+func (s *SQLStore) selectQuery(column, order string, single bool) string {
+	var where, limit string
+	if column != "" {
+		where = fmt.Sprintf(`
+	WHERE
+		%s = %s`, column, s.dialect.Placeholder(1))
+	}
+
+	if single {
+		limit = `
+	LIMIT 1`
+	}
+
+	return fmt.Sprintf(`
+	SELECT
+		%s
+	FROM
+		%s%s
+	ORDER BY
+		id %s%s`, selectFields, s.table(), where, order, limit)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMigration scans a row that was selected with selectFields
+func (s *SQLStore) scanMigration(row rowScanner) (Migration, error) {
+	mig := Migration{}
+	var timestamp, created any
+	var checksum, host, version, operator sql.NullString
+	var duration, pid sql.NullInt64
+	fields := []any{
+		&mig.ID,
+		&mig.Name,
+		&mig.Direction,
+		&mig.Status,
+		&mig.Error,
+		&timestamp,
+		&created,
+		&checksum,
+		&duration,
+		&host,
+		&pid,
+		&version,
+		&operator,
+	}
+
+	err := row.Scan(fields...)
+	if err != nil {
+		return mig, err
+	}
+
+	mig.Checksum = checksum.String
+	mig.Duration = time.Duration(duration.Int64)
+	mig.Host = host.String
+	mig.PID = int(pid.Int64)
+	mig.Version = version.String
+	mig.Operator = operator.String
+
+	mig.Timestamp, err = s.dialect.DecodeTime(timestamp)
+	if err != nil {
+		return mig, err
+	}
+
+	mig.Created, err = s.dialect.DecodeTime(created)
+
+	return mig, err
+}
+
+func (s *SQLStore) queryMigration(query string, args ...any) (*Migration, error) {
+	mig, err := s.scanMigration(s.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, NoResultsError{OriginalError: err}
+		}
+
+		return nil, err
+	}
+
+	return &mig, nil
+}
+
+func (s *SQLStore) queryMigrations(query string, args ...any) (MigrationSet, error) {
+	migs := MigrationSet{}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return migs, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		mig, err := s.scanMigration(rows)
+		if err != nil {
+			return migs, err
+		}
+
+		migs = append(migs, mig)
+	}
+
+	return migs, rows.Err()
+}
+
+// dialect helpers
+
+// newLockOwner returns a token identifying the holder of a lock. It starts
+// with the host and pid so that operators can find a lock's holder, and it
+// fits in the owner column
+func newLockOwner() (string, error) {
+	token := make([]byte, 8)
+	_, err := rand.Read(token)
+
+	host, _ := os.Hostname()
+	if len(host) > 36 {
+		host = host[:36]
+	}
+
+	return fmt.Sprintf(`%v-%d-%v`, host, os.Getpid(), hex.EncodeToString(token)), err
+}
+
+func dollarPlaceholder(n int) string {
+	return fmt.Sprintf(`$%d`, n)
+}
+
+func doubleQuote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// timeFormats are the text representations of a timestamp that the
+// supported drivers may return
+var timeFormats = []string{
+	time.RFC1123Z,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// decodeTime converts a scanned timestamp column into a time.Time. Drivers
+// either return a time.Time or its text representation
+func decodeTime(src any) (time.Time, error) {
+	var text string
+
+	switch val := src.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return val, nil
+	case []byte:
+		text = string(val)
+	case string:
+		text = val
+	default:
+		return time.Time{}, fmt.Errorf(`unable to convert %T to a time.Time`, src)
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return time.Time{}, nil
+	}
+
+	for _, format := range timeFormats {
+		t, err := time.Parse(format, text)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(`unable to parse timestamp %q`, text)
+}
@@ -0,0 +1,164 @@
+package fofm_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emehrkay/fofm"
+)
+
+// atDialect is a dialect for a made up database that uses @pN placeholders,
+// bracket quoting and unix timestamps
+type atDialect struct{}
+
+func (d atDialect) Placeholder(n int) string {
+	return fmt.Sprintf(`@p%d`, n)
+}
+
+func (d atDialect) QuoteIdentifier(name string) string {
+	return "[" + name + "]"
+}
+
+func (d atDialect) CreateStatements(schema, table string) []string {
+	return []string{fmt.Sprintf(`CREATE TABLE %s`, table)}
+}
+
+func (d atDialect) EncodeTime(t time.Time) any {
+	return t.Unix()
+}
+
+func (d atDialect) DecodeTime(src any) (time.Time, error) {
+	return time.Unix(src.(int64), 0), nil
+}
+
+func TestSQLStoreUsesDialect(t *testing.T) {
+	db, fd := newFakeDB()
+	store := fofm.NewSQLStoreWithSchema(db, atDialect{}, "dbo", "runs")
+
+	err := store.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create store -- %v`, err)
+	}
+
+	if fd.execs[0].query != `CREATE TABLE [dbo].[runs]` {
+		t.Errorf(`unexpected create statement -- %v`, fd.execs[0].query)
+	}
+
+	ts := time.Unix(1658164360, 0)
+	err = store.Save(fofm.Migration{Name: "Migration_1_up", Direction: "up", Status: fofm.STATUS_SUCCESS, Timestamp: ts}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	stmt := fd.lastExec()
+	if !strings.Contains(stmt.query, `(@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8, @p9, @p10, @p11, @p12)`) {
+		t.Errorf(`expected the dialect placeholders -- %v`, stmt.query)
+	}
+
+	if stmt.args[4] != ts.Unix() {
+		t.Errorf(`expected the timestamp to be encoded by the dialect got %v`, stmt.args[4])
+	}
+
+	fd.setRows(storeColumns, []driver.Value{int64(1), "Migration_1_up", "up", fofm.STATUS_SUCCESS, "", ts.Unix(), ts.Unix(), "", nil, nil, nil, nil, nil})
+	mig, err := store.LastRunByName("Migration_1_up")
+	if err != nil {
+		t.Fatalf(`unable to get last run -- %v`, err)
+	}
+
+	if !strings.Contains(fd.lastQuery().query, `name = @p1`) {
+		t.Errorf(`expected the dialect placeholder -- %v`, fd.lastQuery().query)
+	}
+
+	if !mig.Timestamp.Equal(ts) {
+		t.Errorf(`expected the timestamp to be decoded by the dialect got %v`, mig.Timestamp)
+	}
+}
+
+func TestSQLiteReadsTimestamps(t *testing.T) {
+	db, err := fofm.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	err = db.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create store -- %v`, err)
+	}
+
+	ts := time.Unix(1658164360, 0)
+	err = db.Save(fofm.Migration{Name: "Migration_1_up", Direction: "up", Status: fofm.STATUS_SUCCESS, Timestamp: ts}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	mig, err := db.LastRun()
+	if err != nil {
+		t.Fatalf(`unable to get last run -- %v`, err)
+	}
+
+	if !mig.Timestamp.Equal(ts) {
+		t.Errorf(`expected the timestamp %v got %v`, ts, mig.Timestamp)
+	}
+
+	if mig.Created.IsZero() {
+		t.Errorf(`expected created to be set`)
+	}
+}
+
+func TestSQLStoreUpgradesExistingTables(t *testing.T) {
+	db, err := fofm.NewSQLite(":memory:")
+	if err != nil {
+		t.Fatalf(`unable to make db -- %v`, err)
+	}
+
+	// the table as it was created by the first version of fofm
+	_, err = db.SQLDB().Exec(`CREATE TABLE function_migrations (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL, 
+		direction TEXT NOT NULL, 
+		timestamp TEXT NOT NULL, 
+		status TEXT NOT NULL,
+		error TEXT NULL,
+		created TEXT NOT NULL
+	)`)
+	if err != nil {
+		t.Fatalf(`unable to create the old table -- %v`, err)
+	}
+
+	_, err = db.SQLDB().Exec(`INSERT INTO function_migrations (name, direction, timestamp, status, error, created) VALUES ('Migration_1_up', 'up', '', 'success', '', '')`)
+	if err != nil {
+		t.Fatalf(`unable to insert into the old table -- %v`, err)
+	}
+
+	err = db.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to upgrade the store -- %v`, err)
+	}
+
+	// running it again should not try to add the columns again
+	err = db.CreateStore()
+	if err != nil {
+		t.Fatalf(`unable to create the store a second time -- %v`, err)
+	}
+
+	err = db.Save(fofm.Migration{Name: "Migration_2_up", Direction: "up", Status: fofm.STATUS_SUCCESS, Checksum: "abc", Duration: time.Second, Host: "web-1"}, nil)
+	if err != nil {
+		t.Fatalf(`unable to save -- %v`, err)
+	}
+
+	list, err := db.List()
+	if err != nil {
+		t.Fatalf(`unable to list -- %v`, err)
+	}
+
+	if len(list) != 2 || list[0].Checksum != "" || list[1].Checksum != "abc" {
+		t.Errorf(`unexpected records after the upgrade -- %+v`, list)
+	}
+
+	if len(list) == 2 && (list[0].Host != "" || list[1].Duration != time.Second || list[1].Host != "web-1") {
+		t.Errorf(`expected the run metadata to be kept after the upgrade -- %+v`, list)
+	}
+}
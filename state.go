@@ -0,0 +1,127 @@
+package fofm
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	STATE_APPLIED     = "applied"
+	STATE_NOT_APPLIED = "not applied"
+	STATE_FAILED      = "failed"
+)
+
+// MigrationState is where an up migration stands after every recorded run
+type MigrationState struct {
+	_         struct{}  `json:"-"`
+	Migration Migration `json:"migration"`
+
+	// State is STATE_APPLIED when the last successful run was up,
+	// STATE_FAILED when the up migration failed after that and
+	// STATE_NOT_APPLIED otherwise
+	State string `json:"state"`
+
+	// LastRun is the most recent run in either direction
+	LastRun *Migration `json:"last_run,omitempty"`
+
+	// Applied is the successful up run when the migration is applied
+	Applied *Migration `json:"applied,omitempty"`
+}
+
+// Is reports whether the migration is in the state
+func (s MigrationState) Is(state string) bool {
+	return s.State == state
+}
+
+// MigrationSetState is the state of every up migration in order
+type MigrationSetState struct {
+	_          struct{}         `json:"-"`
+	Migrations []MigrationState `json:"migration_states"`
+}
+
+// ByTimestamp returns the state of the migration with the timestamp or nil
+func (s MigrationSetState) ByTimestamp(timestamp time.Time) *MigrationState {
+	for i := range s.Migrations {
+		if s.Migrations[i].Migration.Timestamp.Equal(timestamp) {
+			return &s.Migrations[i]
+		}
+	}
+
+	return nil
+}
+
+// Applied returns the applied migrations in the order that they were applied
+func (s MigrationSetState) Applied() []MigrationState {
+	applied := []MigrationState{}
+	for _, state := range s.Migrations {
+		if state.Is(STATE_APPLIED) {
+			applied = append(applied, state)
+		}
+	}
+
+	sort.SliceStable(applied, func(i, j int) bool {
+		return applied[i].Applied.ID < applied[j].Applied.ID
+	})
+
+	return applied
+}
+
+// AppliedState folds every run recorded in the Store into the state of each
+// up migration. Runs are paired with migrations by the id in their names so
+// that a migration that was renamed, for example to add a description, keeps
+// its state
+func (m *FOFM) AppliedState() (MigrationSetState, error) {
+	list, err := m.DB.List()
+	if err != nil {
+		return MigrationSetState{}, err
+	}
+
+	return m.foldState(list), nil
+}
+
+func (m *FOFM) foldState(list MigrationSet) MigrationSetState {
+	states := map[int64]*MigrationState{}
+	for _, mig := range m.UpMigrations {
+		states[mig.Timestamp.Unix()] = &MigrationState{
+			Migration: mig,
+			State:     STATE_NOT_APPLIED,
+		}
+	}
+
+	for i := range list {
+		run := list[i]
+		timestamp, direction, err := MigrationNameParts(run.Name)
+		if err != nil {
+			continue
+		}
+
+		state, ok := states[timestamp.Unix()]
+		if !ok {
+			continue
+		}
+
+		state.LastRun = &run
+
+		switch {
+		case direction == up && run.Status == STATUS_SUCCESS:
+			state.State = STATE_APPLIED
+			state.Applied = &run
+		case direction == up && run.Status == STATUS_FAILURE:
+			state.State = STATE_FAILED
+			state.Applied = nil
+		case direction == down && run.Status == STATUS_SUCCESS:
+			state.State = STATE_NOT_APPLIED
+			state.Applied = nil
+		}
+
+		// canceled runs did not change anything and a failed down
+		// migration leaves the migration applied
+	}
+
+	set := MigrationSetState{}
+	for _, mig := range m.UpMigrations {
+		set.Migrations = append(set.Migrations, *states[mig.Timestamp.Unix()])
+	}
+
+	return set
+}
@@ -0,0 +1,17 @@
+// Package basemigration is a FunctionalMigration that embeds BaseMigration
+// and lives outside of the fofm package directory
+package basemigration
+
+import "github.com/emehrkay/fofm"
+
+type Migrations struct {
+	fofm.BaseMigration
+}
+
+func (m Migrations) GetPackageName() string {
+	return "basemigration"
+}
+
+func (m Migrations) Migration_1_up() error {
+	return nil
+}
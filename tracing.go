@@ -0,0 +1,61 @@
+package fofm
+
+import (
+	"context"
+	"errors"
+)
+
+// Tracer starts the spans that operations and migrations run in. The
+// otelfofm package adapts an OpenTelemetry TracerProvider
+type Tracer interface {
+	// StartOperation is called when Latest, Up, Down, Rollback, Redo or Goto
+	// starts. operation is one of the OPERATION_ constants. The returned
+	// context is the parent of every migration that the operation runs
+	StartOperation(ctx context.Context, operation string) (context.Context, Span)
+
+	// StartMigration is called before each migration is run. The returned
+	// context is passed to the hooks and to the migration when it accepts one
+	StartMigration(ctx context.Context, mig Migration) (context.Context, Span)
+}
+
+// Span is ended once its operation or migration has finished. status is one
+// of the STATUS_ constants and err is the error, if there was one
+type Span interface {
+	End(status string, err error)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) StartOperation(ctx context.Context, operation string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopTracer) StartMigration(ctx context.Context, mig Migration) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(status string, err error) {}
+
+// tracer returns the Tracer or one that does nothing
+func (m *FOFM) tracer() Tracer {
+	if m.Tracer == nil {
+		return noopTracer{}
+	}
+
+	return m.Tracer
+}
+
+// operationStatus returns the STATUS_ constant that describes how an
+// operation ended
+func operationStatus(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return STATUS_SUCCESS
+	case ctx.Err() != nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return STATUS_CANCELED
+	}
+
+	return STATUS_FAILURE
+}